@@ -0,0 +1,51 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fabric
+
+import (
+	"encoding/json"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"strings"
+)
+
+// decodeError extract the structured error returned by the chaincode from an error of the SDK
+// The SDK wrap the message of the chaincode in its own error, so the JSON object is searched in the whole message.
+func decodeError(err error) (*model.Error, bool) {
+	message := err.Error()
+	i := strings.Index(message, `{"code":`)
+	if i < 0 {
+		return nil, false
+	}
+	var chaincodeError model.Error
+	if json.NewDecoder(strings.NewReader(message[i:])).Decode(&chaincodeError) != nil || chaincodeError.Code == "" {
+		return nil, false
+	}
+	return &chaincodeError, true
+}
+
+// ErrorCode retrieve the code of an error returned by the chaincode (see the model.Error* codes)
+// An empty code is returned if the error doesn't come from the chaincode.
+func ErrorCode(err error) string {
+	if chaincodeError, ok := err.(*model.Error); ok {
+		return chaincodeError.Code
+	}
+	return ""
+}
+
+// IsRevisionConflict check whether an update failed because the resource changed since the revision expected
+func IsRevisionConflict(err error) bool {
+	chaincodeError, ok := err.(*model.Error)
+	return ok && chaincodeError.Code == model.ErrorConflict && strings.Contains(chaincodeError.Details, model.ErrorRevisionConflict)
+}
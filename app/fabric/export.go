@@ -0,0 +1,329 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fabric
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"io"
+	"strconv"
+)
+
+// Kinds of the records of an export
+const (
+	RecordActor           = "actor"
+	RecordLocation        = "location"
+	RecordResource        = "resource"
+	RecordResourceDeleted = "resource-deleted"
+)
+
+// Formats of an export file
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Record entry of an export: an actor, a location or a resource (deleted or not), the fields not used by its kind
+// are empty. The type of a location is its kind and its location is its parent.
+// The records are flat so that they can be written as a CSV line, an export is a list of records in the order
+// they must be imported (the actors first, then the locations from the sites to the rooms, then the resources).
+type Record struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Org         string `json:"org"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Shareable   bool   `json:"shareable,omitempty"`
+	Location    string `json:"location,omitempty"`
+}
+
+// recordColumns header of an export in CSV
+var recordColumns = []string{"kind", "id", "name", "org", "type", "description", "shareable", "location"}
+
+// Export allow to retrieve the actors of every type, the locations and the resources (deleted or not) visible by the
+// user as records
+func (u *User) Export() ([]Record, error) {
+	var records []Record
+
+	actors, err := u.QueryActors()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the actors: %v", err)
+	}
+	for _, actor := range actors {
+		records = append(records, Record{Kind: RecordActor, ID: actor.ID, Name: actor.Name, Org: actor.Org, Type: actor.Type})
+	}
+
+	// The locations are sorted by level so that the parents are imported before their children
+	locations, err := u.QueryLocations()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the locations: %v", err)
+	}
+	for _, kind := range model.LocationKinds {
+		for _, location := range locations {
+			if location.Kind == kind {
+				records = append(records, Record{Kind: RecordLocation, ID: location.ID, Name: location.Name, Type: location.Kind, Location: location.Parent})
+			}
+		}
+	}
+
+	resources, err := u.QueryResources(model.ResourcesFilterAll)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the resources: %v", err)
+	}
+	for _, resource := range resources {
+		records = append(records, resourceRecord(RecordResource, resource))
+	}
+
+	deleted, err := u.QueryResourcesDeleted()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the deleted resources: %v", err)
+	}
+	for _, resource := range deleted {
+		records = append(records, resourceRecord(RecordResourceDeleted, resource))
+	}
+
+	return records, nil
+}
+
+// resourceRecord internal method that build the record of a resource
+func resourceRecord(kind string, resource model.Resource) Record {
+	return Record{Kind: kind, ID: resource.ID, Org: resource.Owner, Description: resource.Description, Shareable: resource.Shareable, Location: resource.Location}
+}
+
+// Check allow to verify that a record can be imported, as the chaincode would do, without sending anything
+// It returns false if the record is not imported (the deleted resources are kept in an export for the backup only).
+func (r Record) Check() (bool, error) {
+	switch r.Kind {
+	case RecordActor:
+		if !model.IsActorType(r.Type) {
+			return false, fmt.Errorf("the actor type '%s' is unknown", r.Type)
+		}
+		return true, checkRequest("register", map[string]string{"name": r.Name})
+	case RecordLocation:
+		return true, checkRequest("add-location", map[string]string{"location": r.ID, "name": r.Name, "kind": r.Type, "parent": r.Location})
+	case RecordResource:
+		return true, checkRequest("add", map[string]string{"id": r.ID, "description": r.Description, "shareable": strconv.FormatBool(r.Shareable), "location": r.Location})
+	case RecordResourceDeleted:
+		return false, nil
+	default:
+		return false, fmt.Errorf("the record kind '%s' is unknown", r.Kind)
+	}
+}
+
+// CheckOwner allow to verify that a record can be imported by the user, a resource keeps its owner organization
+// so only the users of this organization can import it
+func (r Record) CheckOwner(u *User) error {
+	if r.Kind == RecordResource && r.Org != u.Org.MspID {
+		return fmt.Errorf("the resource is owned by the organization '%s', it must be imported by a user of this organization", r.Org)
+	}
+	return nil
+}
+
+// Stage allow to retrieve the rank of the record in the import, a record only depends on records of lower stages
+// The actors come first, then the locations by level, then the resources that may be located in them.
+func (r Record) Stage() int {
+	switch r.Kind {
+	case RecordActor:
+		return 0
+	case RecordLocation:
+		for i, kind := range model.LocationKinds {
+			if kind == r.Type {
+				return 1 + i
+			}
+		}
+	}
+	return 1 + len(model.LocationKinds)
+}
+
+// checkRequest internal method that check the arguments of an action against its definition
+func checkRequest(name string, args map[string]string) error {
+	action, found := model.FindAction(name)
+	if !found {
+		return fmt.Errorf("the action '%s' is unknown", name)
+	}
+	_, err := action.Positional(args)
+	return err
+}
+
+// Existing actors, locations and resources already in the ledger before an import, the records matching them are skipped so
+// that an import can be resumed or run again without overwriting anything
+type Existing struct {
+	actors    map[string]bool
+	locations map[string]bool
+	resources map[string]bool
+}
+
+// QueryExisting allow to retrieve the actors, the locations and the resources already in the ledger, before an import
+func (u *User) QueryExisting() (*Existing, error) {
+	existing := &Existing{actors: make(map[string]bool), locations: make(map[string]bool), resources: make(map[string]bool)}
+
+	actors, err := u.QueryActors()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the actors: %v", err)
+	}
+	for _, actor := range actors {
+		existing.actors[existingActorKey(actor.Org, actor.Name)] = true
+	}
+
+	locations, err := u.QueryLocations()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the locations: %v", err)
+	}
+	for _, location := range locations {
+		existing.locations[location.ID] = true
+	}
+
+	resources, err := u.QueryResources(model.ResourcesFilterAll)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the resources: %v", err)
+	}
+	for _, resource := range resources {
+		existing.resources[resource.ID] = true
+	}
+
+	return existing, nil
+}
+
+// existingActorKey internal function that identify an actor by its organization (MSP ID) and its name
+func existingActorKey(mspID, name string) string {
+	return mspID + "/" + name
+}
+
+// Import allow to write a record in the ledger, it returns false if the record is not imported (see Record.Check)
+// An actor is registered in the CA of its organization (the one of the user if the organization is unknown) with
+// the password given, then in the ledger, a location is added under its parent, imported before it, a resource is
+// added by the user, so its owner is the user organization and the resources of other organizations are rejected.
+// The records already in the ledger are skipped, an actor already registered in the CA but not in the ledger (an
+// import stopped between the two) is only registered in the ledger.
+func (s *Setup) Import(u *User, record Record, password string, existing *Existing) (bool, error) {
+	imported, err := record.Check()
+	if err != nil || !imported {
+		return false, err
+	}
+	err = record.CheckOwner(u)
+	if err != nil {
+		return false, err
+	}
+
+	switch record.Kind {
+	case RecordActor:
+		org := u.Org
+		for i := range s.Orgs {
+			if s.Orgs[i].MspID == record.Org {
+				org = &s.Orgs[i]
+			}
+		}
+		if existing.actors[existingActorKey(org.MspID, record.Name)] {
+			return false, nil
+		}
+		actor, errLog := s.LogUser(org.ID, record.Name, password)
+		if errLog != nil {
+			err = s.RegisterUser(org.ID, record.Name, password, record.Type)
+		} else {
+			_, err = actor.UpdateRegister()
+		}
+	case RecordLocation:
+		if existing.locations[record.ID] {
+			return false, nil
+		}
+		_, err = u.UpdateAddLocation(record.ID, record.Name, record.Type, record.Location)
+		if chaincodeError, ok := err.(*model.Error); ok && chaincodeError.Code == model.ErrorConflict {
+			return false, nil
+		}
+	case RecordResource:
+		if existing.resources[record.ID] {
+			return false, nil
+		}
+		_, err = u.UpdateAdd(record.ID, record.Description, record.Shareable, record.Location)
+		if chaincodeError, ok := err.(*model.Error); ok && chaincodeError.Code == model.ErrorConflict {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteRecords allow to write the records of an export in the format given
+func WriteRecords(w io.Writer, format string, records []Record) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(recordColumns)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			err = writer.Write([]string{record.Kind, record.ID, record.Name, record.Org, record.Type, record.Description, strconv.FormatBool(record.Shareable), record.Location})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("the format '%s' is unknown, expected '%s' or '%s'", format, FormatJSON, FormatCSV)
+	}
+}
+
+// ReadRecords allow to read the records of an export in the format given
+func ReadRecords(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatJSON:
+		var records []Record
+		err := json.NewDecoder(r).Decode(&records)
+		if err != nil {
+			return nil, err
+		}
+		return records, nil
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(recordColumns)
+		lines, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("the header of the CSV is missing")
+		}
+		for i, column := range recordColumns {
+			if lines[0][i] != column {
+				return nil, fmt.Errorf("the column %d of the CSV must be '%s', not '%s'", i+1, column, lines[0][i])
+			}
+		}
+		records := make([]Record, 0, len(lines)-1)
+		for i, line := range lines[1:] {
+			shareable := false
+			if line[6] != "" {
+				shareable, err = strconv.ParseBool(line[6])
+				if err != nil {
+					return nil, fmt.Errorf("the shareable flag of the line %d is invalid: %v", i+2, err)
+				}
+			}
+			records = append(records, Record{Kind: line[0], ID: line[1], Name: line[2], Org: line[3], Type: line[4], Description: line[5], Shareable: shareable, Location: line[7]})
+		}
+		return records, nil
+	default:
+		return nil, fmt.Errorf("the format '%s' is unknown, expected '%s' or '%s'", format, FormatJSON, FormatCSV)
+	}
+}
@@ -21,16 +21,26 @@ import (
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
 	"sort"
+	"time"
 )
 
 // query internal method that allow to make query to the blockchain chaincode
+// The arguments are the action followed by its arguments in the order of the action definition (see model.Actions).
 func (u *User) query(args [][]byte, responseObject interface{}) error {
 
+	request, err := u.newRequest(args, nil, false)
+	if err != nil {
+		return fmt.Errorf("unable to build the query: %v", err)
+	}
+
 	response, err := u.ChannelClient.Query(
-		channel.Request{ChaincodeID: u.Fabric.ChaincodeID, Fcn: "invoke", Args: append([][]byte{[]byte("query")}, args...)},
+		request,
 		channel.WithRetry(retry.DefaultChannelOpts),
 	)
 	if err != nil {
+		if chaincodeError, ok := decodeError(err); ok {
+			return chaincodeError
+		}
 		return fmt.Errorf("unable to perform the query: %v", err)
 	}
 
@@ -44,6 +54,16 @@ func (u *User) query(args [][]byte, responseObject interface{}) error {
 	return nil
 }
 
+// QueryActor query the blockchain chaincode to retrieve information about the current user connected, whatever its type
+func (u *User) QueryActor() (*model.Actor, error) {
+	var actor *model.Actor
+	err := u.query([][]byte{[]byte("actor")}, &actor)
+	if err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
+
 // QueryAdmin query the blockchain chaincode to retrieve information about the current admin user connected
 func (u *User) QueryAdmin() (*model.Admin, error) {
 	var admin *model.Admin
@@ -64,16 +84,93 @@ func (u *User) QueryConsumer() (*model.Consumer, error) {
 	return consumer, nil
 }
 
+// QueryConsumers query the blockchain chaincode to retrieve every consumer registered
+func (u *User) QueryConsumers() ([]model.Consumer, error) {
+	var consumers []model.Consumer
+	err := u.query([][]byte{[]byte("consumers")}, &consumers)
+	if err != nil {
+		return nil, err
+	}
+	return consumers, nil
+}
+
+// QueryActors query the blockchain chaincode to retrieve every actor registered, whatever its type
+func (u *User) QueryActors() ([]model.Actor, error) {
+	var actors []model.Actor
+	err := u.query([][]byte{[]byte("actors")}, &actors)
+	if err != nil {
+		return nil, err
+	}
+	return actors, nil
+}
+
+// QueryTeams query the blockchain chaincode to retrieve the teams (only the teams of the user if it is a consumer)
+func (u *User) QueryTeams() ([]model.Team, error) {
+	var teams []model.Team
+	err := u.query([][]byte{[]byte("teams")}, &teams)
+	if err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// QueryDelegations query the blockchain chaincode to retrieve the delegations granted by the user or to the user
+func (u *User) QueryDelegations() (model.Delegations, error) {
+	var delegations model.Delegations
+	err := u.query([][]byte{[]byte("delegations")}, &delegations)
+	if err != nil {
+		return nil, err
+	}
+	return delegations, nil
+}
+
 // QueryResources query the blockchain chaincode to retrieve resources
+// The missions the user is authorised to see are retrieved from the private data collection.
 func (u *User) QueryResources(filter string) ([]model.Resource, error) {
+	return u.QueryResourcesInLocation(filter, "")
+}
+
+// QueryResourcesInLocation query the blockchain chaincode to get the resources located in a location or in one of
+// the locations it contains, every resource is returned if the location ID is empty
+func (u *User) QueryResourcesInLocation(filter string, locationID string) ([]model.Resource, error) {
 	var resources []model.Resource
-	err := u.query([][]byte{[]byte("resources"), []byte(filter)}, &resources)
+	err := u.query([][]byte{[]byte("resources"), []byte(filter), []byte(locationID)}, &resources)
 	if err != nil {
 		return nil, err
 	}
+	missions, err := u.QueryResourceMissions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range resources {
+		fillMission(&resources[i], missions)
+	}
 	return resources, nil
 }
 
+// QueryResourceMissions query the blockchain chaincode to retrieve the private missions the user is authorised to see
+func (u *User) QueryResourceMissions() (map[string]model.ResourceMission, error) {
+	var resourceMissions []model.ResourceMission
+	err := u.query([][]byte{[]byte("resource-missions")}, &resourceMissions)
+	if err != nil {
+		return nil, err
+	}
+	missions := make(map[string]model.ResourceMission)
+	for _, resourceMission := range resourceMissions {
+		missions[resourceMission.ResourceID] = resourceMission
+	}
+	return missions, nil
+}
+
+// fillMission internal function that set the mission of a resource if it is known and match the hash in the ledger
+func fillMission(resource *model.Resource, missions map[string]model.ResourceMission) {
+	resourceMission, found := missions[resource.ID]
+	if !found || resource.MissionHash == "" || resource.MissionHash != model.MissionHash(resourceMission.Mission, resourceMission.Salt) {
+		return
+	}
+	resource.Mission = resourceMission.Mission
+}
+
 // QueryResourcesDeleted query the blockchain chaincode to delete a resource
 func (u *User) QueryResourcesDeleted() (model.ResourcesDeleted, error) {
 	var resources model.ResourcesDeleted
@@ -84,6 +181,129 @@ func (u *User) QueryResourcesDeleted() (model.ResourcesDeleted, error) {
 	return resources, nil
 }
 
+// QueryResourcesAt query the blockchain chaincode to get the resources as they were at a given time
+func (u *User) QueryResourcesAt(at time.Time) ([]model.Resource, error) {
+	var resources []model.Resource
+	err := u.query([][]byte{[]byte("resources-at"), []byte(at.Format(time.RFC3339))}, &resources)
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// QueryLocations query the blockchain chaincode to get every location of the location tree
+// The locations are sorted by path, so every location follows the one containing it.
+func (u *User) QueryLocations() (model.Locations, error) {
+	var locations model.Locations
+	err := u.query([][]byte{[]byte("locations")}, &locations)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for _, location := range locations {
+		for _, step := range locations.Path(location.ID) {
+			paths[location.ID] += step.Name + "\x00"
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool { return paths[locations[i].ID] < paths[locations[j].ID] })
+	return locations, nil
+}
+
+// QueryMissions query the blockchain chaincode to get the missions of the organization of the user, the latest
+// started first
+func (u *User) QueryMissions() ([]model.Mission, error) {
+	var missions []model.Mission
+	err := u.query([][]byte{[]byte("missions")}, &missions)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(missions, func(i, j int) bool { return missions[i].Start.After(missions[j].Start) })
+	return missions, nil
+}
+
+// QueryMission query the blockchain chaincode to get a mission with the resources acquired for it and its timeline
+func (u *User) QueryMission(missionID string) (*model.MissionDetail, error) {
+	var detail model.MissionDetail
+	err := u.query([][]byte{[]byte("mission"), []byte(missionID)}, &detail)
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// QueryBalance query the blockchain chaincode to get the credit account of a consumer (of the user if it is a consumer
+// and the consumer ID is empty)
+func (u *User) QueryBalance(consumerID string) (*model.Account, error) {
+	var account model.Account
+	err := u.query([][]byte{[]byte("balance"), []byte(consumerID)}, &account)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// QueryCreditMovements query the blockchain chaincode to get the credit movements of a consumer (of the user if it is
+// a consumer and the consumer ID is empty), the latest first
+func (u *User) QueryCreditMovements(consumerID string) ([]model.CreditMovement, error) {
+	var movements []model.CreditMovement
+	err := u.query([][]byte{[]byte("credit-movements"), []byte(consumerID)}, &movements)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].Time.After(movements[j].Time) })
+	return movements, nil
+}
+
+// QueryReservations query the blockchain chaincode to get the reservations of a resource (of every resource if the
+// resource ID is empty), ordered by start
+func (u *User) QueryReservations(resourceID string) (model.Reservations, error) {
+	var reservations model.Reservations
+	err := u.query([][]byte{[]byte("reservations"), []byte(resourceID)}, &reservations)
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// QueryAuditLog query the blockchain chaincode to get the audit log of the updates, ordered by time
+// Every filter is optional: an empty actor ID or action and a zero time are ignored.
+func (u *User) QueryAuditLog(actorID string, action string, from time.Time, to time.Time) ([]model.AuditEntry, error) {
+	var fromArg, toArg string
+	if !from.IsZero() {
+		fromArg = from.Format(time.RFC3339)
+	}
+	if !to.IsZero() {
+		toArg = to.Format(time.RFC3339)
+	}
+	var entries []model.AuditEntry
+	err := u.query([][]byte{[]byte("audit-log"), []byte(actorID), []byte(action), []byte(fromArg), []byte(toArg)}, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// QueryStaleHoldings query the blockchain chaincode to get the resources of the organization whose holder didn't
+// check in within the window given, the ones forgotten for the longest time first
+func (u *User) QueryStaleHoldings(window time.Duration) ([]model.Resource, error) {
+	var resources []model.Resource
+	err := u.query([][]byte{[]byte("stale-holdings"), []byte(window.String())}, &resources)
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// QueryStats query the blockchain chaincode to get the counts of the resources by status, type, location and holder
+func (u *User) QueryStats() (*model.Stats, error) {
+	var stats model.Stats
+	err := u.query([][]byte{[]byte("stats")}, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
 // QueryResource query the blockchain chaincode to get resource details
 func (u *User) QueryResource(resourceID string) (*model.Resource, model.ResourceHistories, error) {
 	var resourceHistories model.ResourceHistories
@@ -92,6 +312,13 @@ func (u *User) QueryResource(resourceID string) (*model.Resource, model.Resource
 		return nil, nil, err
 	}
 	sort.Sort(resourceHistories)
+	missions, err := u.QueryResourceMissions()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range resourceHistories {
+		fillMission(&resourceHistories[i].Resource, missions)
+	}
 	// Retrieve the last valid resource in history
 	for i := 0; i < len(resourceHistories); i++ {
 		if !resourceHistories[i].Deleted {
@@ -100,3 +327,13 @@ func (u *User) QueryResource(resourceID string) (*model.Resource, model.Resource
 	}
 	return nil, resourceHistories, nil
 }
+
+// QuerySchema query the blockchain chaincode to retrieve the schema version of the ledger
+func (u *User) QuerySchema() (*model.Schema, error) {
+	var schema model.Schema
+	err := u.query([][]byte{[]byte("schema")}, &schema)
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
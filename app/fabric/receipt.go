@@ -0,0 +1,298 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fabric
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"math/big"
+	"time"
+)
+
+// Transaction update committed in the ledger: its ID, the number of the block containing it (zero if the block
+// can't be retrieved), the response payload endorsed by the peers and the receipt proving the endorsement
+type Transaction struct {
+	ID      string
+	Block   uint64
+	Payload []byte
+	Receipt *Receipt
+}
+
+// Receipt proof that the peers endorsed an update, it can be checked offline (see VerifyReceipt)
+// The header and the proposal are the parts of the proposal signed through the endorsements (the proposal without
+// its transient map, so that the confidential data are not part of the receipt), they hold the transaction ID, its
+// time and the request sent to the chaincode. The block number is the one reported when the transaction was
+// committed, it is not covered by the endorsements.
+type Receipt struct {
+	Channel      string               `json:"channel"`
+	Chaincode    string               `json:"chaincode"`
+	Transaction  string               `json:"transaction"`
+	Block        uint64               `json:"block"`
+	Time         time.Time            `json:"time"`
+	Request      model.Request        `json:"request"`
+	Payload      []byte               `json:"payload"`
+	Header       []byte               `json:"header"`
+	Proposal     []byte               `json:"proposal"`
+	Endorsements []ReceiptEndorsement `json:"endorsements"`
+}
+
+// ReceiptEndorsement endorsement of an update by a peer: its serialized identity (MSP ID and certificate), the
+// proposal response it signed and the signature
+type ReceiptEndorsement struct {
+	MspID     string `json:"mspId"`
+	Endorser  []byte `json:"endorser"`
+	Response  []byte `json:"response"`
+	Signature []byte `json:"signature"`
+}
+
+// newReceipt internal method that build the receipt of an update from the response of the channel client
+func (u *User) newReceipt(request channel.Request, response channel.Response) (*Receipt, error) {
+	if response.Proposal == nil || response.Proposal.Proposal == nil {
+		return nil, fmt.Errorf("the proposal of the transaction is missing")
+	}
+
+	// The peers sign the hash of the proposal without its transient map
+	var proposalPayload pb.ChaincodeProposalPayload
+	err := proto.Unmarshal(response.Proposal.Payload, &proposalPayload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the proposal payload: %v", err)
+	}
+	proposal, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: proposalPayload.Input})
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the proposal payload without transient data: %v", err)
+	}
+
+	receipt := &Receipt{
+		Channel:     u.Fabric.ChannelID,
+		Chaincode:   request.ChaincodeID,
+		Transaction: string(response.TransactionID),
+		Payload:     response.Payload,
+		Header:      response.Proposal.Header,
+		Proposal:    proposal,
+	}
+	if len(request.Args) > 0 {
+		err = json.Unmarshal(request.Args[0], &receipt.Request)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the request envelope: %v", err)
+		}
+	}
+	receipt.Time, err = receiptTime(receipt.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endorsement := range response.Responses {
+		if endorsement.ProposalResponse == nil || endorsement.ProposalResponse.Endorsement == nil {
+			continue
+		}
+		var identity msp.SerializedIdentity
+		err = proto.Unmarshal(endorsement.ProposalResponse.Endorsement.Endorser, &identity)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the identity of the endorser '%s': %v", endorsement.Endorser, err)
+		}
+		receipt.Endorsements = append(receipt.Endorsements, ReceiptEndorsement{
+			MspID:     identity.Mspid,
+			Endorser:  endorsement.ProposalResponse.Endorsement.Endorser,
+			Response:  endorsement.ProposalResponse.Payload,
+			Signature: endorsement.ProposalResponse.Endorsement.Signature,
+		})
+	}
+	return receipt, nil
+}
+
+// receiptTime internal method that read the time of the transaction from the header of its proposal
+func receiptTime(header []byte) (time.Time, error) {
+	var proposalHeader common.Header
+	err := proto.Unmarshal(header, &proposalHeader)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read the proposal header: %v", err)
+	}
+	var channelHeader common.ChannelHeader
+	err = proto.Unmarshal(proposalHeader.ChannelHeader, &channelHeader)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read the channel header: %v", err)
+	}
+	if channelHeader.Timestamp == nil {
+		return time.Time{}, fmt.Errorf("the time of the transaction is missing")
+	}
+	return time.Unix(channelHeader.Timestamp.Seconds, int64(channelHeader.Timestamp.Nanos)).UTC(), nil
+}
+
+// VerifyReceipt check offline a receipt against the certificates of the certificate authorities trusted
+// Every endorsement must be signed by a peer whose certificate is issued by one of the authorities, for the proposal
+// of the receipt and with the payload of the receipt as response. The transaction ID, channel, chaincode, time and
+// request of the receipt must match the ones of the proposal. The MSP IDs of the endorsers are returned.
+func VerifyReceipt(receipt *Receipt, authorities *x509.CertPool) ([]string, error) {
+	if len(receipt.Endorsements) == 0 {
+		return nil, fmt.Errorf("the receipt has no endorsement")
+	}
+
+	var proposalHeader common.Header
+	err := proto.Unmarshal(receipt.Header, &proposalHeader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the proposal header: %v", err)
+	}
+	var channelHeader common.ChannelHeader
+	err = proto.Unmarshal(proposalHeader.ChannelHeader, &channelHeader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the channel header: %v", err)
+	}
+	if channelHeader.TxId != receipt.Transaction {
+		return nil, fmt.Errorf("the transaction ID '%s' is not the one of the proposal '%s'", receipt.Transaction, channelHeader.TxId)
+	}
+	if channelHeader.ChannelId != receipt.Channel {
+		return nil, fmt.Errorf("the channel '%s' is not the one of the proposal '%s'", receipt.Channel, channelHeader.ChannelId)
+	}
+	at, err := receiptTime(receipt.Header)
+	if err != nil {
+		return nil, err
+	}
+	if !at.Equal(receipt.Time) {
+		return nil, fmt.Errorf("the time %s is not the one of the proposal %s", receipt.Time.Format(time.RFC3339Nano), at.Format(time.RFC3339Nano))
+	}
+
+	// The request of the receipt must be the one sent to the chaincode
+	var proposalPayload pb.ChaincodeProposalPayload
+	err = proto.Unmarshal(receipt.Proposal, &proposalPayload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the proposal payload: %v", err)
+	}
+	var invocation pb.ChaincodeInvocationSpec
+	err = proto.Unmarshal(proposalPayload.Input, &invocation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the chaincode invocation: %v", err)
+	}
+	if invocation.ChaincodeSpec == nil || invocation.ChaincodeSpec.ChaincodeId == nil || invocation.ChaincodeSpec.Input == nil {
+		return nil, fmt.Errorf("the chaincode invocation is incomplete")
+	}
+	if invocation.ChaincodeSpec.ChaincodeId.Name != receipt.Chaincode {
+		return nil, fmt.Errorf("the chaincode '%s' is not the one of the proposal '%s'", receipt.Chaincode, invocation.ChaincodeSpec.ChaincodeId.Name)
+	}
+	args := invocation.ChaincodeSpec.Input.Args
+	if len(args) != 2 || string(args[0]) != model.RequestFunction {
+		return nil, fmt.Errorf("the proposal is not a request to the chaincode")
+	}
+	request, err := json.Marshal(receipt.Request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert the request of the receipt: %v", err)
+	}
+	var proposalRequest model.Request
+	err = json.Unmarshal(args[1], &proposalRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the request of the proposal: %v", err)
+	}
+	expected, err := json.Marshal(proposalRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert the request of the proposal: %v", err)
+	}
+	if !bytes.Equal(request, expected) {
+		return nil, fmt.Errorf("the request of the receipt is not the one of the proposal")
+	}
+
+	// The peers sign the hash of the channel header, the signature header and the payload without transient map
+	proposalHash := receiptProposalHash(&proposalHeader, receipt.Proposal)
+
+	var endorsers []string
+	for i, endorsement := range receipt.Endorsements {
+		mspID, err := verifyEndorsement(endorsement, authorities, at)
+		if err != nil {
+			return nil, fmt.Errorf("the endorsement %d is invalid: %v", i+1, err)
+		}
+
+		var responsePayload pb.ProposalResponsePayload
+		err = proto.Unmarshal(endorsement.Response, &responsePayload)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the response of the endorsement %d: %v", i+1, err)
+		}
+		if !bytes.Equal(responsePayload.ProposalHash, proposalHash[:]) {
+			return nil, fmt.Errorf("the endorsement %d is not for the proposal of the receipt", i+1)
+		}
+		var action pb.ChaincodeAction
+		err = proto.Unmarshal(responsePayload.Extension, &action)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the chaincode action of the endorsement %d: %v", i+1, err)
+		}
+		if action.Response == nil || action.Response.Status >= 400 {
+			return nil, fmt.Errorf("the endorsement %d is not a success", i+1)
+		}
+		if !bytes.Equal(action.Response.Payload, receipt.Payload) {
+			return nil, fmt.Errorf("the payload of the receipt is not the one endorsed by the endorsement %d", i+1)
+		}
+		endorsers = append(endorsers, mspID)
+	}
+	return endorsers, nil
+}
+
+// receiptProposalHash internal function that compute the hash of a proposal as the peers do in their responses: the
+// hash of the channel header, the signature header and the proposal payload without its transient map
+func receiptProposalHash(header *common.Header, proposal []byte) [sha256.Size]byte {
+	var hashed []byte
+	hashed = append(hashed, header.ChannelHeader...)
+	hashed = append(hashed, header.SignatureHeader...)
+	hashed = append(hashed, proposal...)
+	return sha256.Sum256(hashed)
+}
+
+// verifyEndorsement internal method that check the signature of an endorsement and the certificate of the endorser
+// at the time of the transaction, the MSP ID of the endorser is returned
+func verifyEndorsement(endorsement ReceiptEndorsement, authorities *x509.CertPool, at time.Time) (string, error) {
+	var identity msp.SerializedIdentity
+	err := proto.Unmarshal(endorsement.Endorser, &identity)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the identity of the endorser: %v", err)
+	}
+	if identity.Mspid != endorsement.MspID {
+		return "", fmt.Errorf("the MSP ID '%s' is not the one of the endorser '%s'", endorsement.MspID, identity.Mspid)
+	}
+	block, _ := pem.Decode(identity.IdBytes)
+	if block == nil {
+		return "", fmt.Errorf("the certificate of the endorser is not PEM encoded")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the certificate of the endorser: %v", err)
+	}
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: authorities, CurrentTime: at, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	if err != nil {
+		return "", fmt.Errorf("the certificate of the endorser is not issued by a trusted authority: %v", err)
+	}
+
+	publicKey, ok := certificate.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("the key of the endorser is not an ECDSA key")
+	}
+	var signature struct{ R, S *big.Int }
+	_, err = asn1.Unmarshal(endorsement.Signature, &signature)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the signature: %v", err)
+	}
+	digest := sha256.Sum256(append(append([]byte{}, endorsement.Response...), endorsement.Endorser...))
+	if !ecdsa.Verify(publicKey, digest[:], signature.R, signature.S) {
+		return "", fmt.Errorf("the signature doesn't match the endorser")
+	}
+	return identity.Mspid, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fabric
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// receiptFixture proposal of an acquisition with a confidential mission, endorsed by a peer of Org1MSP, and the
+// authority that issued the certificate of the peer
+type receiptFixture struct {
+	user        *User
+	request     channel.Request
+	response    channel.Response
+	authorities *x509.CertPool
+}
+
+const fixtureMission = "Top secret mission"
+
+// newReceiptFixture build the proposal and the proposal response the way the SDK and the peer do
+func newReceiptFixture(t *testing.T) *receiptFixture {
+	caKey, caCert := newCertificate(t, nil, nil, true)
+	peerKey, peerCert := newCertificate(t, caKey, caCert, false)
+	authorities := x509.NewCertPool()
+	authorities.AddCert(caCert)
+
+	user := &User{Username: "alice", Fabric: &Setup{ChannelID: "chainhero", ChaincodeID: "resource-manager"}}
+	request, err := user.newRequest(
+		[][]byte{[]byte("acquire"), []byte("resource-1"), []byte(""), []byte(""), []byte("1"), []byte("0"), []byte("")},
+		map[string][]byte{model.TransientMission: []byte(fixtureMission), model.TransientMissionSalt: bytes.Repeat([]byte{7}, model.MissionSaltSize)},
+		true,
+	)
+	if err != nil {
+		t.Fatalf("unable to build the request: %v", err)
+	}
+
+	// The proposal built by the SDK
+	txTime := time.Date(2018, 6, 1, 10, 30, 0, 123000000, time.UTC)
+	channelHeader := mustMarshal(t, &common.ChannelHeader{
+		Type:      3,
+		ChannelId: user.Fabric.ChannelID,
+		TxId:      "tx-1",
+		Timestamp: &timestamp.Timestamp{Seconds: txTime.Unix(), Nanos: int32(txTime.Nanosecond())},
+	})
+	creator := mustMarshal(t, &msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: []byte("client certificate")})
+	signatureHeader := mustMarshal(t, &common.SignatureHeader{Creator: creator, Nonce: []byte("nonce")})
+	header := mustMarshal(t, &common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	invocation := mustMarshal(t, &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeId: &pb.ChaincodeID{Name: request.ChaincodeID},
+		Input:       &pb.ChaincodeInput{Args: append([][]byte{[]byte(request.Fcn)}, request.Args...)},
+	}})
+	payload := mustMarshal(t, &pb.ChaincodeProposalPayload{Input: invocation, TransientMap: request.TransientMap})
+	proposal := &pb.Proposal{Header: header, Payload: payload}
+
+	// The response signed by the peer, for the hash of the proposal without its transient map
+	var hashed []byte
+	hashed = append(hashed, channelHeader...)
+	hashed = append(hashed, signatureHeader...)
+	hashed = append(hashed, mustMarshal(t, &pb.ChaincodeProposalPayload{Input: invocation})...)
+	proposalHash := sha256.Sum256(hashed)
+	result := []byte(`{"id":"resource-1"}`)
+	action := mustMarshal(t, &pb.ChaincodeAction{Response: &pb.Response{Status: 200, Payload: result}})
+	responsePayload := mustMarshal(t, &pb.ProposalResponsePayload{ProposalHash: proposalHash[:], Extension: action})
+	endorser := mustMarshal(t, &msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peerCert.Raw})})
+	digest := sha256.Sum256(append(append([]byte{}, responsePayload...), endorser...))
+	signature, err := ecdsa.SignASN1(rand.Reader, peerKey, digest[:])
+	if err != nil {
+		t.Fatalf("unable to sign the response: %v", err)
+	}
+
+	response := channel.Response{
+		Proposal: &fab.TransactionProposal{TxnID: "tx-1", Proposal: proposal},
+		Responses: []*fab.TransactionProposalResponse{{
+			Endorser: "peer0.org1",
+			Status:   200,
+			ProposalResponse: &pb.ProposalResponse{
+				Response:    &pb.Response{Status: 200, Payload: result},
+				Payload:     responsePayload,
+				Endorsement: &pb.Endorsement{Endorser: endorser, Signature: signature},
+			},
+		}},
+		TransactionID: "tx-1",
+		Payload:       result,
+	}
+	return &receiptFixture{user: user, request: request, response: response, authorities: authorities}
+}
+
+// newCertificate generate a key and its certificate, self-signed if no parent is given
+func newCertificate(t *testing.T, parentKey *ecdsa.PrivateKey, parent *x509.Certificate, authority bool) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "peer0.org1"},
+		NotBefore:             time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	if authority {
+		template.Subject.CommonName = "ca.org1"
+		template.IsCA = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("unable to create the certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to read the certificate: %v", err)
+	}
+	return key, certificate
+}
+
+func mustMarshal(t *testing.T, message proto.Message) []byte {
+	messageAsByte, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatalf("unable to marshal the message: %v", err)
+	}
+	return messageAsByte
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	fixture := newReceiptFixture(t)
+	receipt, err := fixture.user.newReceipt(fixture.request, fixture.response)
+	if err != nil {
+		t.Fatalf("unable to build the receipt: %v", err)
+	}
+
+	// The receipt is downloaded as JSON and checked offline
+	receiptAsByte, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("unable to convert the receipt: %v", err)
+	}
+	var downloaded Receipt
+	err = json.Unmarshal(receiptAsByte, &downloaded)
+	if err != nil {
+		t.Fatalf("unable to read the receipt: %v", err)
+	}
+	var proposalPayload pb.ChaincodeProposalPayload
+	err = proto.Unmarshal(downloaded.Proposal, &proposalPayload)
+	if err != nil {
+		t.Fatalf("unable to read the proposal of the receipt: %v", err)
+	}
+	if len(proposalPayload.TransientMap) > 0 || bytes.Contains(downloaded.Proposal, []byte(fixtureMission)) {
+		t.Fatalf("the receipt contains the confidential mission")
+	}
+
+	endorsers, err := VerifyReceipt(&downloaded, fixture.authorities)
+	if err != nil {
+		t.Fatalf("the receipt is rejected: %v", err)
+	}
+	if len(endorsers) != 1 || endorsers[0] != "Org1MSP" {
+		t.Fatalf("the endorsers are %v, expected [Org1MSP]", endorsers)
+	}
+}
+
+func TestVerifyReceiptRejectsTampering(t *testing.T) {
+	fixture := newReceiptFixture(t)
+
+	tests := []struct {
+		name   string
+		tamper func(receipt *Receipt, authorities **x509.CertPool)
+	}{
+		{"payload", func(receipt *Receipt, _ **x509.CertPool) { receipt.Payload = []byte(`{"id":"resource-2"}`) }},
+		{"transaction", func(receipt *Receipt, _ **x509.CertPool) { receipt.Transaction = "tx-2" }},
+		{"time", func(receipt *Receipt, _ **x509.CertPool) { receipt.Time = receipt.Time.Add(time.Hour) }},
+		{"chaincode", func(receipt *Receipt, _ **x509.CertPool) { receipt.Chaincode = "other" }},
+		{"signature", func(receipt *Receipt, _ **x509.CertPool) { receipt.Endorsements[0].Signature[8] ^= 0xff }},
+		{"proposal", func(receipt *Receipt, _ **x509.CertPool) {
+			receipt.Proposal = mustMarshal(t, &pb.ChaincodeProposalPayload{Input: []byte("other")})
+		}},
+		{"authority", func(_ *Receipt, authorities **x509.CertPool) { *authorities = x509.NewCertPool() }},
+		{"endorsements", func(receipt *Receipt, _ **x509.CertPool) { receipt.Endorsements = nil }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receipt, err := fixture.user.newReceipt(fixture.request, fixture.response)
+			if err != nil {
+				t.Fatalf("unable to build the receipt: %v", err)
+			}
+			receipt.Endorsements[0].Signature = append([]byte{}, receipt.Endorsements[0].Signature...)
+			authorities := fixture.authorities
+			test.tamper(receipt, &authorities)
+			_, err = VerifyReceipt(receipt, authorities)
+			if err == nil {
+				t.Fatalf("the receipt with a tampered %s is accepted", test.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fabric
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+)
+
+// newRequest internal method that build the request sent to the chaincode from the action name and its arguments
+// in the order of the action definition (see model.Actions), the arguments are checked against the definition
+// before being sent by name in the versioned request envelope.
+func (u *User) newRequest(args [][]byte, transientMap map[string][]byte, write bool) (channel.Request, error) {
+	if len(args) < 1 {
+		return channel.Request{}, fmt.Errorf("the action of the request is missing")
+	}
+	action, found := model.FindAction(string(args[0]))
+	if !found {
+		return channel.Request{}, fmt.Errorf("the action '%s' is unknown", args[0])
+	}
+	if action.Write != write {
+		return channel.Request{}, fmt.Errorf("the action '%s' doesn't match the kind of request", action.Name)
+	}
+
+	positional := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		positional = append(positional, string(arg))
+	}
+	named, err := action.Named(positional)
+	if err != nil {
+		return channel.Request{}, err
+	}
+	_, err = action.Positional(named)
+	if err != nil {
+		return channel.Request{}, err
+	}
+
+	envelope, err := json.Marshal(model.NewRequest(action.Name, named))
+	if err != nil {
+		return channel.Request{}, fmt.Errorf("unable to build the request envelope: %v", err)
+	}
+
+	return channel.Request{ChaincodeID: u.Fabric.ChaincodeID, Fcn: model.RequestFunction, Args: [][]byte{envelope}, TransientMap: transientMap}, nil
+}
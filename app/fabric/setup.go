@@ -16,7 +16,9 @@ package fabric
 
 import (
 	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
 	caMsp "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
 	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
@@ -26,9 +28,13 @@ import (
 	packager "github.com/hyperledger/fabric-sdk-go/pkg/fab/ccpackager/gopackager"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/common/cauthdsl"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
 )
 
 // Setup implementation of the Hyperledger Fabric blockchain SDK
+// The first organization of the list is the default one, used when no organization is given.
+// The chaincode policy is the endorsement policy of the chaincode (e.g. "OR('Org1MSP.peer','Org2MSP.peer')"),
+// if empty any member of the organizations given is able to endorse.
 type Setup struct {
 	ChannelID           string
 	ChannelConfig       string
@@ -36,23 +42,33 @@ type Setup struct {
 	ChaincodeGoPath     string
 	ChaincodePath       string
 	ChaincodeVersion    string
-	OrgID               string
-	OrgMspID            string
-	OrgAdminUser        string
+	ChaincodePolicy     string
+	Orgs                []Org
 	OrdererOrgID        string
 	OrdererOrgAdminUser string
 	OrdererID           string
-	CaID                string
 	ConfigFile          string
 	sdk                 *fabsdk.FabricSDK
-	caClient            *caMsp.Client
+	caClients           map[string]*caMsp.Client
+}
+
+// Org an organization of the consortium, owning its peers, its CA and its resources
+type Org struct {
+	ID          string
+	MspID       string
+	AdminUser   string
+	CaID        string
+	Affiliation string
+	Peers       []string
 }
 
 // User stuct that allow a registered user to query and invoke the blockchain
 type User struct {
 	Username        string
+	Org             *Org
 	Fabric          *Setup
 	ChannelClient   *channel.Client
+	LedgerClient    *ledger.Client
 	SigningIdentity msp.SigningIdentity
 }
 
@@ -67,11 +83,18 @@ func (s *Setup) Initialize() error {
 	}
 	s.sdk = sdk
 
-	caClient, err := caMsp.New(sdk.Context())
-	if err != nil {
-		return fmt.Errorf("failed to create new CA client: %v", err)
+	if len(s.Orgs) == 0 {
+		return fmt.Errorf("at least one organization is required")
+	}
+
+	s.caClients = make(map[string]*caMsp.Client)
+	for _, org := range s.Orgs {
+		caClient, err := caMsp.New(sdk.Context(), caMsp.WithOrg(org.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create new CA client for the organization '%s': %v", org.ID, err)
+		}
+		s.caClients[org.ID] = caClient
 	}
-	s.caClient = caClient
 
 	fmt.Printf("Fabric SDK initialised.\n")
 
@@ -83,7 +106,7 @@ func (s *Setup) Install() error {
 	fmt.Printf("Preparing contexts to create channel...\n")
 
 	//clientContext allows creation of transactions using the supplied identity as the credential.
-	clientContext := s.sdk.Context(fabsdk.WithUser(s.OrgAdminUser), fabsdk.WithOrg(s.OrdererOrgID))
+	clientContext := s.sdk.Context(fabsdk.WithUser(s.OrdererOrgAdminUser), fabsdk.WithOrg(s.OrdererOrgID))
 
 	// Resource management client is responsible for managing channels (create/update channel)
 	// Supply user that has privileges to create channel (in this case orderer admin)
@@ -100,29 +123,85 @@ func (s *Setup) Install() error {
 
 	fmt.Printf("Preparing contexts to make peers joinning the new channel...\n")
 
-	// Prepare context
-	adminContext := s.sdk.Context(fabsdk.WithUser(s.OrgAdminUser), fabsdk.WithOrg(s.OrgID))
+	var orgResMgmts []*resmgmt.Client
+	for _, org := range s.Orgs {
+		// Prepare context
+		adminContext := s.sdk.Context(fabsdk.WithUser(org.AdminUser), fabsdk.WithOrg(org.ID))
+
+		// Org resource management client
+		orgResMgmt, err := resmgmt.New(adminContext)
+		if err != nil {
+			return fmt.Errorf("failed to create new resource management client for the organization '%s': %s", org.ID, err)
+		}
+
+		// Org peers join channel
+		if err = orgResMgmt.JoinChannel(s.ChannelID, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(s.OrdererID)); err != nil {
+			return fmt.Errorf("peers of the organization '%s' failed to JoinChannel: %s", org.ID, err)
+		}
 
-	// Org resource management client
-	orgResMgmt, err := resmgmt.New(adminContext)
+		fmt.Printf("Peers of the organization '%s' joined the channel '%s'\n", org.ID, s.ChannelID)
+
+		orgResMgmts = append(orgResMgmts, orgResMgmt)
+	}
+
+	err = s.createCC(orgResMgmts)
 	if err != nil {
-		return fmt.Errorf("failed to create new resource management client: %s", err)
+		return fmt.Errorf("unable to install and instantiate the chaincode: %v", err)
 	}
 
-	// Org peers join channel
-	if err = orgResMgmt.JoinChannel(s.ChannelID, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(s.OrdererID)); err != nil {
-		return fmt.Errorf("org peers failed to JoinChannel: %s", err)
+	fmt.Printf("Fabric channel installed and chaincode installed/instantiated.\n")
+
+	return nil
+}
+
+// Upgrade allow to install the chaincode version set up on the peers of every organization and to upgrade
+// the chaincode instantiated on the channel to it, the chaincode migrate the data of the ledger during the upgrade.
+func (s *Setup) Upgrade() error {
+	fmt.Printf("Preparing contexts to upgrade the chaincode...\n")
+
+	var orgResMgmts []*resmgmt.Client
+	for _, org := range s.Orgs {
+		// Prepare context
+		adminContext := s.sdk.Context(fabsdk.WithUser(org.AdminUser), fabsdk.WithOrg(org.ID))
+
+		// Org resource management client
+		orgResMgmt, err := resmgmt.New(adminContext)
+		if err != nil {
+			return fmt.Errorf("failed to create new resource management client for the organization '%s': %s", org.ID, err)
+		}
+		orgResMgmts = append(orgResMgmts, orgResMgmt)
 	}
 
-	fmt.Printf("Peers joined the channel '%s'\n", s.ChannelID)
+	err := s.installCC(orgResMgmts)
+	if err != nil {
+		return fmt.Errorf("unable to install the chaincode: %v", err)
+	}
 
-	err = s.createCC(orgResMgmt)
+	fmt.Printf("Upgrade chaincode...\n")
+
+	ccPolicy, err := s.chaincodePolicy()
 	if err != nil {
-		return fmt.Errorf("unable to install and instantiate the chaincode: %v", err)
+		return err
 	}
 
-	fmt.Printf("Fabric channel installed and chaincode installed/instantiated.\n")
+	// The default org resource manager will upgrade the chaincode on channel
+	resp, err := orgResMgmts[0].UpgradeCC(
+		s.ChannelID,
+		resmgmt.UpgradeCCRequest{
+			Name:       s.ChaincodeID,
+			Path:       s.ChaincodePath,
+			Version:    s.ChaincodeVersion,
+			Args:       [][]byte{[]byte("init")},
+			Policy:     ccPolicy,
+			CollConfig: s.missionsCollectionConfigs(),
+		},
+		resmgmt.WithRetry(retry.DefaultResMgmtOpts),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to upgrade the chaincode: %v", err)
+	}
 
+	fmt.Printf("Chaincode '%s' upgraded to version '%s' with transaction ID '%s'\n", s.ChaincodeID, s.ChaincodeVersion, resp.TransactionID)
 	return nil
 }
 
@@ -131,24 +210,77 @@ func (s *Setup) CloseSDK() {
 	s.sdk.Close()
 }
 
-// LogUser allow to login a user using credentials provided and retrieve the blockchain user related
-func (s *Setup) LogUser(username, password string) (*User, error) {
+// Org retrieve an organization configured using its ID, the default organization is returned if the ID is empty
+func (s *Setup) Org(orgID string) (*Org, error) {
+	if orgID == "" && len(s.Orgs) > 0 {
+		return &s.Orgs[0], nil
+	}
+	for i := range s.Orgs {
+		if s.Orgs[i].ID == orgID {
+			return &s.Orgs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the organization '%s' is unknown", orgID)
+}
+
+// endorsingPeers retrieve the peers of the organizations given (by MSP ID) able to endorse a transaction
+func (s *Setup) endorsingPeers(mspIDs []string) ([]string, error) {
+	var peers []string
+	for _, mspID := range mspIDs {
+		found := false
+		for _, org := range s.Orgs {
+			if org.MspID == mspID && len(org.Peers) > 0 {
+				peers = append(peers, org.Peers...)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no peer known for the organization '%s'", mspID)
+		}
+	}
+	return peers, nil
+}
 
-	err := s.caClient.Enroll(username, caMsp.WithSecret(password))
+// LogUser allow to login a user of an organization using credentials provided and retrieve the blockchain user related
+func (s *Setup) LogUser(orgID, username, password string) (*User, error) {
+
+	org, err := s.Org(orgID)
+	if err != nil {
+		return nil, err
+	}
+	caClient := s.caClients[org.ID]
+
+	err = caClient.Enroll(username, caMsp.WithSecret(password))
 	if err != nil {
 		return nil, fmt.Errorf("failed to enroll identity '%s': %v", username, err)
 	}
 
-	var user User
-	user.Username = username
-	user.Fabric = s
+	return s.enrolledUser(org, username)
+}
+
+// LogEnrolledUser allow to retrieve the blockchain user of an organization already enrolled by the application, from
+// the credentials kept by the SDK, without its password
+func (s *Setup) LogEnrolledUser(orgID, username string) (*User, error) {
+	org, err := s.Org(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrolledUser(org, username)
+}
+
+// enrolledUser internal method that build the blockchain user of an organization from its enrolled identity
+func (s *Setup) enrolledUser(org *Org, username string) (*User, error) {
+	caClient := s.caClients[org.ID]
 
-	user.SigningIdentity, err = s.caClient.GetSigningIdentity(username)
+	user := User{Username: username, Org: org, Fabric: s}
+
+	var err error
+	user.SigningIdentity, err = caClient.GetSigningIdentity(username)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get signing identity for '%s': %v", username, err)
 	}
 
-	clientChannelContext := s.sdk.ChannelContext(s.ChannelID, fabsdk.WithUser(username), fabsdk.WithOrg(s.OrgID), fabsdk.WithIdentity(user.SigningIdentity))
+	clientChannelContext := s.sdk.ChannelContext(s.ChannelID, fabsdk.WithUser(username), fabsdk.WithOrg(org.ID), fabsdk.WithIdentity(user.SigningIdentity))
 
 	// Channel client is used to query and execute transactions
 	user.ChannelClient, err = channel.New(clientChannelContext)
@@ -156,37 +288,54 @@ func (s *Setup) LogUser(username, password string) (*User, error) {
 		return nil, fmt.Errorf("failed to create new channel client for '%s': %v", username, err)
 	}
 
+	// Ledger client is used to find the block of the transactions committed
+	user.LedgerClient, err = ledger.New(clientChannelContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new ledger client for '%s': %v", username, err)
+	}
+
 	return &user, nil
 }
 
-// RegisterUser register a user to the Fabric CA client and into the blockchain using invoke on the chaincode
-func (s *Setup) RegisterUser(username, password, userType string) error {
+// RegisterUser register a user to the Fabric CA client of an organization and into the blockchain using invoke on the chaincode
+// The user type is one of the actor types (admin, manager, auditor or consumer).
+func (s *Setup) RegisterUser(orgID, username, password, userType string) error {
 	fmt.Printf("Register user '%s'... \n", username)
-	_, err := s.caClient.Register(&caMsp.RegistrationRequest{
+
+	if !model.IsActorType(userType) {
+		return fmt.Errorf("the user type '%s' is unknown", userType)
+	}
+
+	org, err := s.Org(orgID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.caClients[org.ID].Register(&caMsp.RegistrationRequest{
 		Name:           username,
 		Secret:         password,
 		Type:           "user",
 		MaxEnrollments: -1,
-		Affiliation:    "org1",
+		Affiliation:    org.Affiliation,
 		Attributes: []caMsp.Attribute{
 			{
-				Name:  "actor",
+				Name:  model.ActorAttribute,
 				Value: userType,
 				ECert: true,
 			},
 		},
-		CAName: s.CaID,
+		CAName: org.CaID,
 	})
 	if err != nil {
 		return fmt.Errorf("unable to register user '%s': %v", username, err)
 	}
 
-	u, err := s.LogUser(username, password)
+	u, err := s.LogUser(org.ID, username, password)
 	if err != nil {
 		return fmt.Errorf("unable to log user '%s' after registration: %v", username, err)
 	}
 
-	err = u.UpdateRegister()
+	_, err = u.UpdateRegister()
 	if err != nil {
 		return fmt.Errorf("unable to add the user '%s' in the ledger: %v", username, err)
 	}
@@ -200,19 +349,24 @@ func (s *Setup) RegisterUser(username, password, userType string) error {
 func (s *Setup) createChannel(resMgmtClient *resmgmt.Client) error {
 	fmt.Printf("Creating channel...\n")
 
-	mspClient, err := mspclient.New(s.sdk.Context(), mspclient.WithOrg(s.OrgID))
-	if err != nil {
-		return err
-	}
-	adminIdentity, err := mspClient.GetSigningIdentity(s.OrgAdminUser)
-	if err != nil {
-		return err
+	// Every organization admin sign the channel creation
+	var adminIdentities []msp.SigningIdentity
+	for _, org := range s.Orgs {
+		mspClient, err := mspclient.New(s.sdk.Context(), mspclient.WithOrg(org.ID))
+		if err != nil {
+			return err
+		}
+		adminIdentity, err := mspClient.GetSigningIdentity(org.AdminUser)
+		if err != nil {
+			return err
+		}
+		adminIdentities = append(adminIdentities, adminIdentity)
 	}
 
 	req := resmgmt.SaveChannelRequest{
 		ChannelID:         s.ChannelID,
 		ChannelConfigPath: s.ChannelConfig,
-		SigningIdentities: []msp.SigningIdentity{adminIdentity},
+		SigningIdentities: adminIdentities,
 	}
 
 	txID, err := resMgmtClient.SaveChannel(req, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(s.OrdererID))
@@ -224,8 +378,47 @@ func (s *Setup) createChannel(resMgmtClient *resmgmt.Client) error {
 	return nil
 }
 
-// createCC internal method that allow to install and instantiate a chaincode in the blockchain network
-func (s *Setup) createCC(orgResMgmt *resmgmt.Client) error {
+// createCC internal method that allow to install the chaincode on the peers of every organization and instantiate it in the blockchain network
+func (s *Setup) createCC(orgResMgmts []*resmgmt.Client) error {
+	err := s.installCC(orgResMgmts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Instantiate chaincode...\n")
+
+	// Set up chaincode policy
+	ccPolicy, err := s.chaincodePolicy()
+	if err != nil {
+		return err
+	}
+
+	// Set up the private data collections storing the missions
+	collConfigs := s.missionsCollectionConfigs()
+
+	// The default org resource manager will instantiate the chaincode on channel
+	resp, err := orgResMgmts[0].InstantiateCC(
+		s.ChannelID,
+		resmgmt.InstantiateCCRequest{
+			Name:       s.ChaincodeID,
+			Path:       s.ChaincodePath,
+			Version:    s.ChaincodeVersion,
+			Args:       [][]byte{[]byte("init")},
+			Policy:     ccPolicy,
+			CollConfig: collConfigs,
+		},
+		resmgmt.WithRetry(retry.DefaultResMgmtOpts),
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Chaincode '%s' (version '%s') instantiated with transaction ID '%s'\n", s.ChaincodeID, s.ChaincodeVersion, resp.TransactionID)
+	return nil
+}
+
+// installCC internal method that install the chaincode on the peers of every organization
+func (s *Setup) installCC(orgResMgmts []*resmgmt.Client) error {
 	fmt.Printf("Install chaincode...\n")
 
 	ccPkg, err := packager.NewCCPackage(s.ChaincodePath, s.ChaincodeGoPath)
@@ -240,34 +433,68 @@ func (s *Setup) createCC(orgResMgmt *resmgmt.Client) error {
 		Version: s.ChaincodeVersion,
 		Package: ccPkg,
 	}
-	_, err = orgResMgmt.InstallCC(installCCReq, resmgmt.WithRetry(retry.DefaultResMgmtOpts))
-	if err != nil {
-		return err
+	for _, orgResMgmt := range orgResMgmts {
+		_, err = orgResMgmt.InstallCC(installCCReq, resmgmt.WithRetry(retry.DefaultResMgmtOpts))
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Printf("Chaincode '%s' installed (version '%s')\n", s.ChaincodeID, s.ChaincodeVersion)
+	return nil
+}
 
-	fmt.Printf("Instantiate chaincode...\n")
+// chaincodePolicy internal method that build the endorsement policy of the chaincode
+// It applies to the objects shared by the organizations (teams, locations...), every resource has a key-level policy
+// requiring the endorsement of its owner organization, set by the chaincode when the resource is added.
+func (s *Setup) chaincodePolicy() (*common.SignaturePolicyEnvelope, error) {
+	if s.ChaincodePolicy != "" {
+		ccPolicy, err := cauthdsl.FromString(s.ChaincodePolicy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the chaincode policy '%s': %v", s.ChaincodePolicy, err)
+		}
+		return ccPolicy, nil
+	}
+	return cauthdsl.SignedByAnyMember(s.mspIDs()), nil
+}
 
-	// Set up chaincode policy
-	ccPolicy := cauthdsl.SignedByAnyMember([]string{s.OrgMspID})
+// missionsCollectionConfigs internal method that build the configurations of the private data collections storing the
+// missions: each organization is the only member of the collection of the missions of its resources, so the peers of
+// the other organizations never receive them. The collection shared by every organization before is kept, since a
+// collection can't be removed on upgrade, until its missions are migrated (see UpdateMigrate).
+func (s *Setup) missionsCollectionConfigs() []*common.CollectionConfig {
+	configs := []*common.CollectionConfig{missionsCollectionConfig(model.CollectionMissions, s.mspIDs())}
+	for _, mspID := range s.mspIDs() {
+		configs = append(configs, missionsCollectionConfig(model.MissionsCollection(mspID), []string{mspID}))
+	}
+	return configs
+}
 
-	// Org resource manager will instantiate the chaincode on channel
-	resp, err := orgResMgmt.InstantiateCC(
-		s.ChannelID,
-		resmgmt.InstantiateCCRequest{
-			Name:    s.ChaincodeID,
-			Path:    s.ChaincodePath,
-			Version: s.ChaincodeVersion,
-			Args:    [][]byte{[]byte("init")},
-			Policy:  ccPolicy,
+// missionsCollectionConfig internal function that build the configuration of a private data collection storing
+// missions, the chaincode is in charge of restricting the access to the missions for the members of the collection.
+func missionsCollectionConfig(name string, mspIDs []string) *common.CollectionConfig {
+	return &common.CollectionConfig{
+		Payload: &common.CollectionConfig_StaticCollectionConfig{
+			StaticCollectionConfig: &common.StaticCollectionConfig{
+				Name: name,
+				MemberOrgsPolicy: &common.CollectionPolicyConfig{
+					Payload: &common.CollectionPolicyConfig_SignaturePolicy{
+						SignaturePolicy: cauthdsl.SignedByAnyMember(mspIDs),
+					},
+				},
+				RequiredPeerCount: 0,
+				MaximumPeerCount:  3,
+				BlockToLive:       0,
+			},
 		},
-		resmgmt.WithRetry(retry.DefaultResMgmtOpts),
-	)
-	if err != nil {
-		return err
 	}
+}
 
-	fmt.Printf("Chaincode '%s' (version '%s') instantiated with transaction ID '%s'\n", s.ChaincodeID, s.ChaincodeVersion, resp.TransactionID)
-	return nil
+// mspIDs internal method that list the MSP IDs of the organizations
+func (s *Setup) mspIDs() []string {
+	var mspIDs []string
+	for _, org := range s.Orgs {
+		mspIDs = append(mspIDs, org.MspID)
+	}
+	return mspIDs
 }
@@ -15,54 +15,384 @@
 package fabric
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// update internal method that allow a user to invoke on the blockchain chaincode
-func (u *User) update(args [][]byte, responseObject interface{}) error {
+// update internal method that allow a user to invoke on the blockchain chaincode, the transaction committed is returned
+// The arguments are the action followed by its arguments in the order of the action definition (see model.Actions).
+// The transient map allow to give confidential data that will not be recorded in the transaction.
+func (u *User) update(args [][]byte, transientMap map[string][]byte, responseObject interface{}, options ...channel.RequestOption) (*Transaction, error) {
+
+	request, err := u.newRequest(args, transientMap, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the update: %v", err)
+	}
 
 	response, err := u.ChannelClient.Execute(
-		channel.Request{ChaincodeID: u.Fabric.ChaincodeID, Fcn: "invoke", Args: append([][]byte{[]byte("update")}, args...)},
-		channel.WithRetry(retry.DefaultChannelOpts),
+		request,
+		append([]channel.RequestOption{channel.WithRetry(retry.DefaultChannelOpts)}, options...)...,
 	)
 	if err != nil {
-		return fmt.Errorf("unable to perform the update: %v", err)
+		if chaincodeError, ok := decodeError(err); ok {
+			return nil, chaincodeError
+		}
+		return nil, fmt.Errorf("unable to perform the update: %v", err)
 	}
 
 	if responseObject != nil {
 		err = json.Unmarshal(response.Payload, responseObject)
 		if err != nil {
-			return fmt.Errorf("unable to convert response to the object given for the update: %v", err)
+			return nil, fmt.Errorf("unable to convert response to the object given for the update: %v", err)
+		}
+	}
+
+	// The update is committed, so a failure to build its receipt or to find its block doesn't fail the update
+	transaction := &Transaction{ID: string(response.TransactionID), Payload: response.Payload}
+	transaction.Receipt, err = u.newReceipt(request, response)
+	if err != nil {
+		fmt.Printf("Unable to build the receipt of the transaction '%s': %v\n", transaction.ID, err)
+	}
+	block, err := u.LedgerClient.QueryBlockByTxID(response.TransactionID)
+	if err != nil {
+		fmt.Printf("Unable to retrieve the block of the transaction '%s': %v\n", transaction.ID, err)
+	} else if block.Header != nil {
+		transaction.Block = block.Header.Number
+		if transaction.Receipt != nil {
+			transaction.Receipt.Block = block.Header.Number
 		}
 	}
 
-	return nil
+	return transaction, nil
+}
+
+// updateResource internal method that allow a user to invoke an update of a resource on the blockchain chaincode
+// The proposal is sent to the peers of the organizations that must endorse the changes of the resource, the owner
+// organization by default. The companions of the resource may be changed with it, so the organizations endorsing
+// them are targeted too.
+func (u *User) updateResource(resourceID string, args [][]byte, transientMap map[string][]byte, responseObject interface{}) (*Transaction, error) {
+	return u.updateResources([]string{resourceID}, args, transientMap, responseObject)
+}
+
+// updateResources internal method that allow a user to invoke an update of several resources on the blockchain
+// chaincode, the proposal is sent to the peers of the organizations endorsing any of them (see updateResource).
+func (u *User) updateResources(resourceIDs []string, args [][]byte, transientMap map[string][]byte, responseObject interface{}) (*Transaction, error) {
+	// Only the resources given and their companions are retrieved, the ones visible by the user are the ones it can
+	// update, whatever its actor type
+	var resources []model.Resource
+	err := u.query([][]byte{[]byte("resources-by-id"), []byte(strings.Join(resourceIDs, ","))}, &resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var endorsingOrgs []string
+	known := make(map[string]bool)
+	for _, resource := range resources {
+		orgs := resource.EndorsingOrgs
+		if len(orgs) == 0 && resource.Owner != "" {
+			orgs = []string{resource.Owner}
+		}
+		for _, org := range orgs {
+			if !known[org] {
+				known[org] = true
+				endorsingOrgs = append(endorsingOrgs, org)
+			}
+		}
+	}
+	if len(endorsingOrgs) == 0 {
+		return u.update(args, transientMap, responseObject)
+	}
+	peers, err := u.Fabric.endorsingPeers(endorsingOrgs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to target the endorsing peers of the resource: %v", err)
+	}
+	return u.update(args, transientMap, responseObject, channel.WithTargetEndpoints(peers...))
 }
 
 // UpdateRegister allow to register a user into the blockchain
-func (u *User) UpdateRegister() error {
-	return u.update([][]byte{[]byte("register"), []byte(u.Username)}, nil)
+func (u *User) UpdateRegister() (*Transaction, error) {
+	return u.update([][]byte{[]byte("register"), []byte(u.Username)}, nil, nil)
+}
+
+// UpdateAdd allow to add a resource owned by the user organization into the blockchain, located in the given
+// location if the location ID is not empty
+func (u *User) UpdateAdd(resourceID, resourceDescription string, shareable bool, locationID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("add"), []byte(resourceID), []byte(resourceDescription), []byte(strconv.FormatBool(shareable)), []byte(locationID)}, nil, nil)
 }
 
-// UpdateAdd allow to add a resource into the blockchain
-func (u *User) UpdateAdd(resourceID, resourceDescription string) error {
-	return u.update([][]byte{[]byte("add"), []byte(resourceID), []byte(resourceDescription)}, nil)
+// UpdateEdit allow to edit the description of a resource into the blockchain
+// Every update of a resource take the revision of the resource expected by the user, the update fails with
+// a revision conflict (see IsRevisionConflict) if the resource changed since, an empty revision skip the check.
+func (u *User) UpdateEdit(resourceID, resourceDescription string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("edit"), []byte(resourceID), []byte(resourceDescription), []byte(revision)}, nil, nil)
 }
 
 // UpdateDelete allow to delete a resource into the blockchain
-func (u *User) UpdateDelete(resourceID string) error {
-	return u.update([][]byte{[]byte("delete"), []byte(resourceID)}, nil)
+func (u *User) UpdateDelete(resourceID string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("delete"), []byte(resourceID), []byte(revision)}, nil, nil)
+}
+
+// UpdateAcquire allow to acquire a resource into the blockchain, on behalf of a team if the team ID is not empty
+// and on behalf of a delegator (a consumer that granted a delegation to the user) if the delegator ID is not empty
+// The priority is the level of the acquisition, a resource already acquired is taken from its holder (preemption) if
+// the priority is high enough (see model.CanPreempt). The resource is linked to the mission if the mission ID is not
+// empty, the details of the mission are then optional.
+func (u *User) UpdateAcquire(resourceID string, mission string, teamID string, delegatorID string, revision string, priority int, missionID string) (*Transaction, error) {
+	transientMap, err := missionTransient(mission)
+	if err != nil {
+		return nil, err
+	}
+	return u.updateResource(resourceID, [][]byte{[]byte("acquire"), []byte(resourceID), []byte(teamID), []byte(delegatorID), []byte(revision), []byte(strconv.Itoa(priority)), []byte(missionID)}, transientMap, nil)
+}
+
+// missionTransient internal function that build the transient map giving a mission, with a new random salt for its
+// hash in the ledger (see model.MissionHash)
+func missionTransient(mission string) (map[string][]byte, error) {
+	salt := make([]byte, model.MissionSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate the salt of the mission: %v", err)
+	}
+	return map[string][]byte{model.TransientMission: []byte(mission), model.TransientMissionSalt: salt}, nil
+}
+
+// UpdateRelease allow to release a resource into the blockchain, with the condition report of the resource if the
+// report is not nil (a resource reported damaged is put out of service)
+func (u *User) UpdateRelease(resourceID string, revision string, report *model.ConditionReport) (*Transaction, error) {
+	args := [][]byte{[]byte("release"), []byte(resourceID), []byte(revision)}
+	if report != nil {
+		var meter string
+		if report.Meter != nil {
+			meter = strconv.FormatFloat(*report.Meter, 'f', -1, 64)
+		}
+		args = append(args, []byte(report.Condition), []byte(report.Notes), []byte(meter))
+	}
+	return u.updateResource(resourceID, args, nil, nil)
+}
+
+// UpdateRestore allow to put a resource out of service back in service into the blockchain
+func (u *User) UpdateRestore(resourceID string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("restore"), []byte(resourceID), []byte(revision)}, nil, nil)
+}
+
+// UpdateMove allow to move a resource to a location into the blockchain, the resource is no longer located if the
+// location ID is empty
+func (u *User) UpdateMove(resourceID string, locationID string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("move"), []byte(resourceID), []byte(locationID), []byte(revision)}, nil, nil)
+}
+
+// UpdateSetEndorsement allow to set the organizations (by MSP ID) whose peers must endorse every change of a resource
+// into the blockchain, the chaincode endorsement policy applies again to the resource if no organization is given.
+func (u *User) UpdateSetEndorsement(resourceID string, endorsingOrgs []string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("set-endorsement"), []byte(resourceID), []byte(strings.Join(endorsingOrgs, ",")), []byte(revision)}, nil, nil)
+}
+
+// UpdateSetCompanions allow to set the resources required by a resource (bundle) into the blockchain, they are
+// then acquired, renewed and released with it, the resource is no longer a bundle if no companion is given.
+func (u *User) UpdateSetCompanions(resourceID string, companionIDs []string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("set-companions"), []byte(resourceID), []byte(strings.Join(companionIDs, ",")), []byte(revision)}, nil, nil)
+}
+
+// UpdateRenew allow to take over the holding of a resource acquired by the user or its team into the blockchain
+// The mission is kept if the one given is empty.
+func (u *User) UpdateRenew(resourceID string, mission string, revision string) (*Transaction, error) {
+	transientMap, err := missionTransient(mission)
+	if err != nil {
+		return nil, err
+	}
+	return u.updateResource(resourceID, [][]byte{[]byte("renew"), []byte(resourceID), []byte(revision)}, transientMap, nil)
+}
+
+// UpdateReserve allow to reserve a resource for a time slot into the blockchain, on behalf of a team if the team ID
+// is not empty, the reservation is repeated according to the recurrence rule if it is not empty (see model.Recurrence)
+// in the time zone given (a TZID, UTC if empty).
+func (u *User) UpdateReserve(resourceID string, start time.Time, end time.Time, rule string, teamID string, timeZone string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("reserve"), []byte(resourceID), []byte(start.Format(time.RFC3339)), []byte(end.Format(time.RFC3339)), []byte(rule), []byte(teamID), []byte(timeZone)}, nil, nil)
+}
+
+// UpdateCancelReservation allow to cancel a reservation into the blockchain, only the occurrence starting at the
+// given time is cancelled if the time is not zero, else the whole series
+func (u *User) UpdateCancelReservation(resourceID string, reservationID string, occurrence time.Time) (*Transaction, error) {
+	var occurrenceArg string
+	if !occurrence.IsZero() {
+		occurrenceArg = occurrence.Format(time.RFC3339)
+	}
+	return u.update([][]byte{[]byte("cancel-reservation"), []byte(resourceID), []byte(reservationID), []byte(occurrenceArg)}, nil, nil)
+}
+
+// UpdateAddLocation allow to add a location (site, building or room) to the location tree into the blockchain
+func (u *User) UpdateAddLocation(locationID string, name string, kind string, parentID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("add-location"), []byte(locationID), []byte(name), []byte(kind), []byte(parentID)}, nil, nil)
 }
 
-// UpdateAcquire allow to acquire a resource into the blockchain
-func (u *User) UpdateAcquire(resourceID string, mission string) error {
-	return u.update([][]byte{[]byte("acquire"), []byte(resourceID), []byte(mission)}, nil)
+// UpdateDeleteLocation allow to delete an empty location of the location tree into the blockchain
+func (u *User) UpdateDeleteLocation(locationID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("delete-location"), []byte(locationID)}, nil, nil)
 }
 
-// UpdateRelease allow to release a resource into the blockchain
-func (u *User) UpdateRelease(resourceID string) error {
-	return u.update([][]byte{[]byte("release"), []byte(resourceID)}, nil)
+// UpdateAddMission allow to add a mission of the user into the blockchain, the mission has no end if the end is nil
+func (u *User) UpdateAddMission(missionID string, title string, start time.Time, end *time.Time) (*Transaction, error) {
+	var missionEnd string
+	if end != nil {
+		missionEnd = end.Format(time.RFC3339)
+	}
+	return u.update([][]byte{[]byte("add-mission"), []byte(missionID), []byte(title), []byte(start.Format(time.RFC3339)), []byte(missionEnd)}, nil, nil)
+}
+
+// UpdateCloseMission allow to close a mission into the blockchain, every resource still acquired for the mission is
+// released
+func (u *User) UpdateCloseMission(missionID string) (*Transaction, error) {
+	detail, err := u.QueryMission(missionID)
+	if err != nil {
+		return nil, err
+	}
+	var resourceIDs []string
+	for _, resource := range detail.Acquired {
+		resourceIDs = append(resourceIDs, resource.ID)
+	}
+	return u.updateResources(resourceIDs, [][]byte{[]byte("close-mission"), []byte(missionID)}, nil, nil)
+}
+
+// UpdateSetPriority allow to set the highest priority level a consumer can acquire a resource with into the blockchain
+func (u *User) UpdateSetPriority(consumerID string, priority int) (*Transaction, error) {
+	return u.update([][]byte{[]byte("set-priority"), []byte(consumerID), []byte(strconv.Itoa(priority))}, nil, nil)
+}
+
+// UpdateAllocateCredits allow to add credits to the balance of a consumer into the blockchain, a negative amount
+// withdraw credits
+func (u *User) UpdateAllocateCredits(consumerID string, amount int64) (*Transaction, error) {
+	return u.update([][]byte{[]byte("allocate-credits"), []byte(consumerID), []byte(strconv.FormatInt(amount, 10))}, nil, nil)
+}
+
+// UpdateSetCost allow to set the credits charged for every acquisition and every hour of holding of a resource into
+// the blockchain, a cost of zero make it free
+func (u *User) UpdateSetCost(resourceID string, perUse int64, perHour int64, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("set-cost"), []byte(resourceID), []byte(strconv.FormatInt(perUse, 10)), []byte(strconv.FormatInt(perHour, 10)), []byte(revision)}, nil, nil)
+}
+
+// UpdateCheckIn allow to show that the holder of a resource still uses it into the blockchain
+func (u *User) UpdateCheckIn(resourceID string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("check-in"), []byte(resourceID)}, nil, nil)
+}
+
+// UpdateAttach allow to record a document attached to a resource into the blockchain, the document itself is stored
+// off the ledger, only its SHA-256 hash, name, size and MIME type are recorded
+func (u *User) UpdateAttach(resourceID string, hash string, name string, size int64, mimeType string, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("attach"), []byte(resourceID), []byte(hash), []byte(name), []byte(strconv.FormatInt(size, 10)), []byte(mimeType), []byte(revision)}, nil, nil)
+}
+
+// UpdateGrantDelegation allow to grant a delegation to another consumer until the given expiration into the blockchain
+func (u *User) UpdateGrantDelegation(delegateID string, expiration time.Time) (*Transaction, error) {
+	return u.update([][]byte{[]byte("grant-delegation"), []byte(delegateID), []byte(expiration.Format(time.RFC3339))}, nil, nil)
+}
+
+// UpdateRevokeDelegation allow to revoke a delegation previously granted to another consumer into the blockchain
+func (u *User) UpdateRevokeDelegation(delegateID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("revoke-delegation"), []byte(delegateID)}, nil, nil)
+}
+
+// UpdateAddTeam allow to add a team into the blockchain
+func (u *User) UpdateAddTeam(teamID, teamName string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("add-team"), []byte(teamID), []byte(teamName)}, nil, nil)
+}
+
+// UpdateDeleteTeam allow to delete a team into the blockchain
+func (u *User) UpdateDeleteTeam(teamID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("delete-team"), []byte(teamID)}, nil, nil)
+}
+
+// UpdateAddTeamMember allow to add a consumer as member of a team into the blockchain
+func (u *User) UpdateAddTeamMember(teamID, consumerID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("add-team-member"), []byte(teamID), []byte(consumerID)}, nil, nil)
+}
+
+// UpdateRemoveTeamMember allow to remove a consumer from the members of a team into the blockchain
+func (u *User) UpdateRemoveTeamMember(teamID, consumerID string) (*Transaction, error) {
+	return u.update([][]byte{[]byte("remove-team-member"), []byte(teamID), []byte(consumerID)}, nil, nil)
+}
+
+// UpdateShare allow to share or not a resource with the other organizations into the blockchain
+func (u *User) UpdateShare(resourceID string, shareable bool, revision string) (*Transaction, error) {
+	return u.updateResource(resourceID, [][]byte{[]byte("share"), []byte(resourceID), []byte(strconv.FormatBool(shareable)), []byte(revision)}, nil, nil)
+}
+
+// UpdateMigrate allow to apply the pending migrations of the ledger into the blockchain, by batch of the given size
+// (the chaincode default one if zero), the schema returned tell whether another batch is required.
+// The migrations may change the resources of every organization, so the peers of every organization endorse them,
+// with the seed of the salts of the missions moved to private data. A private migration is applied to each
+// organization in turn, endorsed by the peers of the organization only since they are the only members of its
+// collection, then completed by the peers of every organization.
+func (u *User) UpdateMigrate(batchSize int) (*model.Schema, error) {
+	var schema model.Schema
+	batch := ""
+	if batchSize > 0 {
+		batch = strconv.Itoa(batchSize)
+	}
+	transientMap, err := missionTransient("")
+	if err != nil {
+		return nil, err
+	}
+	current, err := u.QuerySchema()
+	if err != nil {
+		return nil, err
+	}
+
+	org := ""
+	endorsingOrgs := u.Fabric.mspIDs()
+	if current.Private {
+		for _, mspID := range u.Fabric.mspIDs() {
+			if !current.IsOrgMigrated(mspID) {
+				org = mspID
+				break
+			}
+		}
+	}
+	if org != "" {
+		endorsingOrgs, err = u.migrationEndorsingOrgs(org)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	peers, err := u.Fabric.endorsingPeers(endorsingOrgs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to target the endorsing peers of the organizations: %v", err)
+	}
+	_, err = u.update([][]byte{[]byte("migrate"), []byte(batch), []byte(org)}, transientMap, &schema, channel.WithTargetEndpoints(peers...))
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// migrationEndorsingOrgs internal method that list the organizations endorsing the private migration of an
+// organization: the organization itself and the other organizations required by the policy of one of its resources,
+// the migration only reads data they all have so they endorse the same writes
+func (u *User) migrationEndorsingOrgs(org string) ([]string, error) {
+	var resources []model.Resource
+	err := u.query([][]byte{[]byte("resources"), []byte(model.ResourcesFilterAll), []byte("")}, &resources)
+	if err != nil {
+		return nil, err
+	}
+	endorsingOrgs := []string{org}
+	known := map[string]bool{org: true}
+	for _, resource := range resources {
+		if resource.Owner != org {
+			continue
+		}
+		for _, endorsingOrg := range resource.EndorsingOrgs {
+			if !known[endorsingOrg] {
+				known[endorsingOrg] = true
+				endorsingOrgs = append(endorsingOrgs, endorsingOrg)
+			}
+		}
+	}
+	return endorsingOrgs, nil
 }
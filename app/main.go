@@ -27,31 +27,100 @@ import (
 // Flags allow to run some parts of the code when executing binary
 const (
 	flagInstall  = "install"
+	flagUpgrade  = "upgrade"
 	flagRegister = "register"
 )
 
+// Flags allow to verify a receipt offline, without the Fabric network
+const (
+	flagVerifyReceipt = "verify-receipt"
+	flagCaCerts       = "ca-certs"
+)
+
+// Flags allow to export the ledger content to a file and to import it in another ledger
+const (
+	flagExport        = "export"
+	flagImport        = "import"
+	flagFormat        = "format"
+	flagDryRun        = "dry-run"
+	flagOffset        = "offset"
+	flagBatchSize     = "batch-size"
+	flagOrg           = "org"
+	flagUser          = "user"
+	flagPassword      = "password"
+	flagActorPassword = "actor-password"
+)
+
+// Flag allow to set the directory storing the documents attached to the resources
+const flagAttachments = "attachments"
+
+// Flag allow to set the file storing the check-in tokens of the users
+const flagCheckInTokens = "check-in-tokens"
+
 func main() {
 	// Manage flags
 	flagsParams := make(map[string]*bool)
 	flagsParams[flagInstall] = flag.Bool(flagInstall, false, "If set, the Fabric channel will be create and the chaincode installed/instantiated.")
+	flagsParams[flagUpgrade] = flag.Bool(flagUpgrade, false, "If set, the chaincode version set up will be installed and the chaincode upgraded to it.")
 	flagsParams[flagRegister] = flag.Bool(flagRegister, false, "If set, users will be registered in the Fabric CA.")
+	receiptFile := flag.String(flagVerifyReceipt, "", "If set, the receipt file given is verified offline against the CA certificates and the application exits.")
+	caCerts := flag.String(flagCaCerts, "", "The CA certificates used to verify a receipt: PEM files or directories, separated by commas.")
+	exportFile := flag.String(flagExport, "", "If set, the actors, the locations and the resources are exported to the file given and the application exits.")
+	importFile := flag.String(flagImport, "", "If set, the records of the export file given are imported and the application exits.")
+	var transfer transferOptions
+	flag.StringVar(&transfer.Format, flagFormat, "", "The format of the export file, 'json' or 'csv', the file extension by default.")
+	flag.BoolVar(&transfer.DryRun, flagDryRun, false, "If set, the records to import are only checked, nothing is sent to the ledger.")
+	flag.IntVar(&transfer.Offset, flagOffset, 0, "The offset of the first record to import, to resume an import stopped.")
+	flag.IntVar(&transfer.BatchSize, flagBatchSize, 10, "The number of records imported concurrently.")
+	flag.StringVar(&transfer.Org, flagOrg, "org1", "The organization of the user exporting or importing.")
+	flag.StringVar(&transfer.Username, flagUser, "admin1", "The user exporting or importing, an admin or an auditor to export, an admin to import.")
+	flag.StringVar(&transfer.Password, flagPassword, "password", "The password of the user exporting or importing.")
+	flag.StringVar(&transfer.ActorPassword, flagActorPassword, "password", "The password of the actors imported in the Fabric CA.")
+	attachmentsDir := flag.String(flagAttachments, "attachments", "The directory of the documents attached to the resources.")
+	checkInTokensFile := flag.String(flagCheckInTokens, "check-in-tokens.json", "The file of the tokens allowing the scripts of the users to check in.")
 	flag.Parse()
 
+	// Verify a receipt, the Fabric SDK is not needed
+	if *receiptFile != "" {
+		err := verifyReceipt(*receiptFile, *caCerts)
+		if err != nil {
+			fmt.Printf("Unable to verify the receipt: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check an import, the Fabric SDK is not needed
+	if *importFile != "" && transfer.DryRun {
+		err := checkExport(*importFile, transfer)
+		if err != nil {
+			fmt.Printf("Unable to import the records: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Definition of the Fabric SDK properties
 	fSetup := fabric.Setup{
-		ChannelID:           "mychannel",
-		ChannelConfig:       os.Getenv("GOPATH") + "/src/github.com/chainHero/resource-manager/fixtures/artifacts/mychannel/channel.tx",
-		ChaincodeID:         "chainhero-resource-manager",
-		ChaincodeGoPath:     os.Getenv("GOPATH"),
-		ChaincodePath:       "github.com/chainHero/resource-manager/chaincode/",
-		ChaincodeVersion:    "v1.0.0",
-		OrgID:               "org1",
-		OrgMspID:            "Org1MSP",
-		OrgAdminUser:        "Admin",
+		ChannelID:        "mychannel",
+		ChannelConfig:    os.Getenv("GOPATH") + "/src/github.com/chainHero/resource-manager/fixtures/artifacts/mychannel/channel.tx",
+		ChaincodeID:      "chainhero-resource-manager",
+		ChaincodeGoPath:  os.Getenv("GOPATH"),
+		ChaincodePath:    "github.com/chainHero/resource-manager/chaincode/",
+		ChaincodeVersion: "v1.0.0",
+		Orgs: []fabric.Org{
+			{
+				ID:          "org1",
+				MspID:       "Org1MSP",
+				AdminUser:   "Admin",
+				CaID:        "ca.org1.hf.chainhero.io",
+				Affiliation: "org1",
+				Peers:       []string{"peer0.org1.hf.chainhero.io", "peer1.org1.hf.chainhero.io"},
+			},
+		},
 		OrdererOrgID:        "ordererorg",
 		OrdererOrgAdminUser: "Admin",
 		OrdererID:           "orderer.hf.chainhero.io",
-		CaID:                "ca.org1.hf.chainhero.io",
 		ConfigFile:          "config.yaml",
 	}
 
@@ -68,31 +137,66 @@ func main() {
 			return
 		}
 	}
+	if *flagsParams[flagUpgrade] {
+		err = fSetup.Upgrade()
+		if err != nil {
+			fmt.Printf("Unable to upgrade the chaincode: %v\n", err)
+			return
+		}
+	}
 	// Close SDK
 	defer fSetup.CloseSDK()
 
 	// Register our users
 	if *flagsParams[flagRegister] {
-		err = fSetup.RegisterUser("admin1", "password", model.ActorAdmin)
+		err = fSetup.RegisterUser("org1", "admin1", "password", model.ActorAdmin)
 		if err != nil {
 			fmt.Printf("Unable to register the user 'admin1': %v\n", err)
 			return
 		}
-		err = fSetup.RegisterUser("consumer1", "password", model.ActorConsumer)
+		err = fSetup.RegisterUser("org1", "manager1", "password", model.ActorManager)
+		if err != nil {
+			fmt.Printf("Unable to register the user 'manager1': %v\n", err)
+			return
+		}
+		err = fSetup.RegisterUser("org1", "auditor1", "password", model.ActorAuditor)
+		if err != nil {
+			fmt.Printf("Unable to register the user 'auditor1': %v\n", err)
+			return
+		}
+		err = fSetup.RegisterUser("org1", "consumer1", "password", model.ActorConsumer)
 		if err != nil {
 			fmt.Printf("Unable to register the user 'consumer1': %v\n", err)
 			return
 		}
-		err = fSetup.RegisterUser("consumer2", "password", model.ActorConsumer)
+		err = fSetup.RegisterUser("org1", "consumer2", "password", model.ActorConsumer)
 		if err != nil {
 			fmt.Printf("Unable to register the user 'consumer2': %v\n", err)
 			return
 		}
 	}
 
+	// Export or import the ledger content instead of serving the web application
+	if *exportFile != "" {
+		err = exportLedger(&fSetup, *exportFile, transfer)
+		if err != nil {
+			fmt.Printf("Unable to export the records: %v\n", err)
+		}
+		return
+	}
+	if *importFile != "" {
+		err = importLedger(&fSetup, *importFile, transfer)
+		if err != nil {
+			fmt.Printf("Unable to import the records: %v\n", err)
+		}
+		return
+	}
+
 	// Launch the web application listening
 	app := &controllers.Controller{
-		Fabric: &fSetup,
+		Fabric:        &fSetup,
+		Attachments:   controllers.AttachmentStore{Dir: *attachmentsDir},
+		CheckInTokens: controllers.CheckInTokens{File: *checkInTokensFile},
 	}
 	err = web.Serve(app)
 	if err != nil {
@@ -0,0 +1,82 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// verifyReceipt allow to check offline a receipt downloaded from the web application against the certificates of
+// the certificate authorities given (PEM files or directories of PEM files, separated by commas)
+func verifyReceipt(receiptFile string, caCerts string) error {
+	authorities := x509.NewCertPool()
+	for _, path := range strings.Split(caCerts, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		files := []string{path}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("unable to read the CA certificates '%s': %v", path, err)
+		}
+		if info.IsDir() {
+			files, err = filepath.Glob(filepath.Join(path, "*.pem"))
+			if err != nil {
+				return fmt.Errorf("unable to list the CA certificates in '%s': %v", path, err)
+			}
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("unable to read the CA certificate '%s': %v", file, err)
+			}
+			if !authorities.AppendCertsFromPEM(content) {
+				return fmt.Errorf("no PEM certificate found in '%s'", file)
+			}
+		}
+	}
+	if len(authorities.Subjects()) == 0 {
+		return fmt.Errorf("at least one CA certificate is required to verify a receipt")
+	}
+
+	content, err := ioutil.ReadFile(receiptFile)
+	if err != nil {
+		return fmt.Errorf("unable to read the receipt '%s': %v", receiptFile, err)
+	}
+	var receipt fabric.Receipt
+	err = json.Unmarshal(content, &receipt)
+	if err != nil {
+		return fmt.Errorf("unable to parse the receipt '%s': %v", receiptFile, err)
+	}
+
+	endorsers, err := fabric.VerifyReceipt(&receipt, authorities)
+	if err != nil {
+		return fmt.Errorf("the receipt is invalid: %v", err)
+	}
+	fmt.Printf("The receipt is valid.\n")
+	fmt.Printf("Transaction: %s (block %d, not covered by the endorsements)\n", receipt.Transaction, receipt.Block)
+	fmt.Printf("Time: %s\n", receipt.Time.UTC().Format("Jan 02, 2006 15:04:05 UTC"))
+	fmt.Printf("Action: %s %v\n", receipt.Request.Action, receipt.Request.Args)
+	fmt.Printf("Endorsed by: %s\n", strings.Join(endorsers, ", "))
+	return nil
+}
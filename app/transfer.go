@@ -0,0 +1,182 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// transferOptions options of the export and import commands
+type transferOptions struct {
+	Format        string
+	DryRun        bool
+	Offset        int
+	BatchSize     int
+	Org           string
+	Username      string
+	Password      string
+	ActorPassword string
+}
+
+// format allow to retrieve the format of an export file, the one given or else the one of the file extension
+func (o transferOptions) format(file string) string {
+	if o.Format != "" {
+		return o.Format
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+}
+
+// exportLedger allow to write the actors, the locations and the resources of the ledger visible by the user in an export file
+func exportLedger(setup *fabric.Setup, file string, options transferOptions) error {
+	u, err := setup.LogUser(options.Org, options.Username, options.Password)
+	if err != nil {
+		return fmt.Errorf("unable to log the user '%s': %v", options.Username, err)
+	}
+	records, err := u.Export()
+	if err != nil {
+		return err
+	}
+
+	output, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("unable to create the export '%s': %v", file, err)
+	}
+	defer output.Close()
+	err = fabric.WriteRecords(output, options.format(file), records)
+	if err != nil {
+		return fmt.Errorf("unable to write the export '%s': %v", file, err)
+	}
+
+	fmt.Printf("%d records exported to '%s'.\n", len(records), file)
+	return nil
+}
+
+// readExport allow to read the records of an export file
+func readExport(file string, options transferOptions) ([]fabric.Record, error) {
+	input, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the export '%s': %v", file, err)
+	}
+	defer input.Close()
+	records, err := fabric.ReadRecords(input, options.format(file))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the export '%s': %v", file, err)
+	}
+	if options.Offset < 0 || options.Offset > len(records) {
+		return nil, fmt.Errorf("the offset %d is out of the %d records of the export", options.Offset, len(records))
+	}
+	return records, nil
+}
+
+// checkExport allow to verify the records of an export file from the offset given, nothing is sent to the ledger
+func checkExport(file string, options transferOptions) error {
+	records, err := readExport(file, options)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for i := options.Offset; i < len(records); i++ {
+		imported, err := records[i].Check()
+		switch {
+		case err != nil:
+			invalid++
+			fmt.Printf("Record %d (%s '%s') is invalid: %v\n", i, records[i].Kind, records[i].ID, err)
+		case !imported:
+			fmt.Printf("Record %d (%s '%s') would be skipped.\n", i, records[i].Kind, records[i].ID)
+		}
+	}
+	fmt.Printf("Dry run: %d records checked from the offset %d, %d invalid.\n", len(records)-options.Offset, options.Offset, invalid)
+	if invalid > 0 {
+		return fmt.Errorf("%d records are invalid", invalid)
+	}
+	return nil
+}
+
+// importLedger allow to write the records of an export file in the ledger from the offset given
+// The records of a batch are sent concurrently, so a batch ends before a record of a later stage (see Record.Stage)
+// that may depend on the records of the batch. The import stops after the first batch with a failure and the
+// offset to resume from is the one of its first record failed. The records already in the ledger are skipped, so
+// resuming from an earlier offset or running the import again is safe. The resources of other organizations are
+// skipped and reported, they are imported by a user of their organization.
+func importLedger(setup *fabric.Setup, file string, options transferOptions) error {
+	records, err := readExport(file, options)
+	if err != nil {
+		return err
+	}
+	u, err := setup.LogUser(options.Org, options.Username, options.Password)
+	if err != nil {
+		return fmt.Errorf("unable to log the user '%s': %v", options.Username, err)
+	}
+	if options.BatchSize < 1 {
+		options.BatchSize = 1
+	}
+	existing, err := u.QueryExisting()
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the records already in the ledger: %v", err)
+	}
+
+	imported, skipped := 0, 0
+	for start := options.Offset; start < len(records); {
+		end := start + 1
+		for end < len(records) && end-start < options.BatchSize && records[end].Stage() == records[start].Stage() {
+			end++
+		}
+
+		results := make([]bool, end-start)
+		errs := make([]error, end-start)
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			if err := records[i].CheckOwner(u); err != nil {
+				fmt.Printf("Record %d (%s '%s') skipped: %v\n", i, records[i].Kind, records[i].ID, err)
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i-start], errs[i-start] = setup.Import(u, records[i], options.ActorPassword, existing)
+			}(i)
+		}
+		wg.Wait()
+
+		failed := -1
+		for i, err := range errs {
+			switch {
+			case err != nil:
+				fmt.Printf("Record %d (%s '%s') failed: %v\n", start+i, records[start+i].Kind, records[start+i].ID, err)
+				if failed < 0 {
+					failed = start + i
+				}
+			case results[i]:
+				imported++
+			default:
+				skipped++
+			}
+		}
+		if failed >= 0 {
+			return fmt.Errorf("the import stopped, resume it with the offset %d", failed)
+		}
+		fmt.Printf("Progress: %d/%d records (%d imported, %d skipped).\n", end, len(records), imported, skipped)
+		start = end
+	}
+
+	fmt.Printf("Import of '%s' completed.\n", file)
+	return nil
+}
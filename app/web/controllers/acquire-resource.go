@@ -19,15 +19,15 @@ import (
 	"github.com/chainHero/resource-manager/app/fabric"
 	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
+	"time"
 )
 
 // AcquireResourceHandler controller that allow to acquire a resource
 func (c *Controller) AcquireResourceHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		// Check that the user connected is a consumer, else return to the resources page
-		_, err := u.QueryConsumer()
-		if err != nil {
+		// Check that the user connected is allowed to acquire a resource, else return to the resources page
+		if !permissions(u)["acquire"] {
 			http.Redirect(w, r, "/resources", http.StatusTemporaryRedirect)
 			return
 		}
@@ -40,6 +40,13 @@ func (c *Controller) AcquireResourceHandler() func(http.ResponseWriter, *http.Re
 			Response            bool
 			PreSelectedResource string
 			Resources           []model.Resource
+			Held                []model.Resource
+			Available           map[string]bool
+			Priorities          []int
+			Teams               []model.Team
+			Delegations         model.Delegations
+			Missions            []model.Mission
+			Transaction         *fabric.Transaction
 			Username            string
 		}{
 			Error:               "",
@@ -47,26 +54,92 @@ func (c *Controller) AcquireResourceHandler() func(http.ResponseWriter, *http.Re
 			Response:            false,
 			PreSelectedResource: preSelectedResource,
 			Resources:           []model.Resource{},
+			Held:                []model.Resource{},
+			Available:           make(map[string]bool),
+			Priorities:          model.Priorities,
+			Teams:               []model.Team{},
+			Delegations:         model.Delegations{},
+			Missions:            []model.Mission{},
 			Username:            u.Username,
 		}
 		if r.FormValue(formSubmittedKey) == formSubmittedValue {
 			resourceID := r.FormValue("resource")
-			mission := r.FormValue("mission")
-			err = u.UpdateAcquire(resourceID, mission)
+			mission := formInput(r, "mission")
+			teamID := r.FormValue("team")
+			delegatorID := r.FormValue("delegator")
+			priority, err := model.ParsePriority(r.FormValue("priority"))
 			if err != nil {
-				data.Error = fmt.Sprintf("Unable to make the transaction in the ledger: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				data.Error = fmt.Sprintf("The request is invalid: %v.", err)
 			} else {
-				data.Success = true
+				data.Error = validateInputs(w, []input{{model.KindMission, mission}})
+			}
+			if data.Error == "" {
+				transaction, err := u.UpdateAcquire(resourceID, mission, teamID, delegatorID, formRevision(r, resourceID), priority, r.FormValue("mission-id"))
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+					data.Transaction = transaction
+					c.receipts.keep(u, transaction)
+				}
 			}
 			data.Response = true
 		}
 
-		resources, err := u.QueryResources(model.ResourcesFilterOnlyAvailable)
+		// The resources held by someone else may be taken with a priority high enough, except the companions of a bundle
+		resources, err := u.QueryResources(model.ResourcesFilterAll)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, resource := range resources {
+			switch {
+			case resource.OutOfService:
+			case resource.Available:
+				data.Resources = append(data.Resources, resource)
+				data.Available[resource.ID] = true
+			case resource.Bundle == "":
+				data.Held = append(data.Held, resource)
+			}
+		}
+
+		teams, err := u.QueryTeams()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve teams from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Teams = teams
+
+		// The consumers that granted an active delegation to the user
+		delegations, err := u.QueryDelegations()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Unable to retrieve delegations from the ledger: %s", errorMessage(err)), errorStatus(err))
 			return
 		}
-		data.Resources = resources
+		consumer, err := u.QueryConsumer()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve consumer from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		now := time.Now()
+		for _, delegation := range delegations {
+			if delegation.Delegate == consumer.ID && delegation.IsActive(now) {
+				data.Delegations = append(data.Delegations, delegation)
+			}
+		}
+
+		// The open missions of the user the resource can be acquired for
+		missions, err := u.QueryMissions()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve missions from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, mission := range missions {
+			if mission.Owner == consumer.ID && mission.IsOpen(now) {
+				data.Missions = append(data.Missions, mission)
+			}
+		}
 
 		renderTemplate(w, r, "acquire-resource.gohtml", data)
 	})
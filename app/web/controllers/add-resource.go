@@ -17,6 +17,7 @@ package controllers
 import (
 	"fmt"
 	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
 )
 
@@ -24,18 +25,18 @@ import (
 func (c *Controller) AddResourceHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		// Check that the user connected is an admin, else return to the home page
-		_, err := u.QueryAdmin()
-		if err != nil {
+		// Check that the user connected is allowed to add a resource, else return to the home page
+		if !permissions(u)["add"] {
 			http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
 			return
 		}
 
 		data := &struct {
-			Error    string
-			Success  bool
-			Response bool
-			Username string
+			Error     string
+			Success   bool
+			Response  bool
+			Locations model.Locations
+			Username  string
 		}{
 			Error:    "",
 			Success:  false,
@@ -43,16 +44,28 @@ func (c *Controller) AddResourceHandler() func(http.ResponseWriter, *http.Reques
 			Username: u.Username,
 		}
 		if r.FormValue(formSubmittedKey) == formSubmittedValue {
-			id := r.FormValue("id")
-			description := r.FormValue("description")
-			err := u.UpdateAdd(id, description)
-			if err != nil {
-				data.Error = fmt.Sprintf("Unable to make the transaction in the ledger: %v", err)
-			} else {
-				data.Success = true
+			id := formInput(r, "id")
+			description := formInput(r, "description")
+			shareable := r.FormValue("shareable") == "true"
+			data.Error = validateInputs(w, []input{{model.KindID, id}, {model.KindDescription, description}})
+			if data.Error == "" {
+				_, err := u.UpdateAdd(id, description, shareable, r.FormValue("location"))
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+				}
 			}
 			data.Response = true
 		}
+
+		locations, err := u.QueryLocations()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve locations from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Locations = locations
+
 		renderTemplate(w, r, "add-resource.gohtml", data)
 	})
 }
@@ -0,0 +1,193 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Status of a document attached to a resource, once checked against the hash recorded in the ledger
+const (
+	attachmentValid    = "valid"
+	attachmentMissing  = "missing"
+	attachmentMismatch = "mismatch"
+)
+
+// AttachmentStore content-addressed store of the documents attached to the resources, on the local disk
+// A document is stored under its SHA-256 hash, so a document attached several times is only stored once and a
+// document changed on the disk no longer matches the hash recorded in the ledger.
+type AttachmentStore struct {
+	Dir string
+}
+
+// path retrieve the path of a document from its hash, the documents are spread in directories by hash prefix
+func (s AttachmentStore) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+// put store a document and return its hash and size, the document must not exceed the maximum size
+func (s AttachmentStore) put(document io.Reader) (string, int64, error) {
+	err := os.MkdirAll(s.Dir, 0700)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to create the attachment store: %v", err)
+	}
+	temporary, err := ioutil.TempFile(s.Dir, "upload-")
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store the document: %v", err)
+	}
+	defer os.Remove(temporary.Name())
+	defer temporary.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temporary, hasher), io.LimitReader(document, model.AttachmentMaxSize+1))
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store the document: %v", err)
+	}
+	if size > model.AttachmentMaxSize {
+		return "", 0, fmt.Errorf("the document exceeds the maximum size of %d bytes", model.AttachmentMaxSize)
+	}
+	err = temporary.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store the document: %v", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	err = os.MkdirAll(filepath.Dir(s.path(hash)), 0700)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store the document: %v", err)
+	}
+	err = os.Rename(temporary.Name(), s.path(hash))
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store the document: %v", err)
+	}
+	return hash, size, nil
+}
+
+// check verify that the document stored under a hash still matches it
+func (s AttachmentStore) check(hash string) string {
+	document, err := os.Open(s.path(hash))
+	if err != nil {
+		return attachmentMissing
+	}
+	defer document.Close()
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, document)
+	if err != nil || hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return attachmentMismatch
+	}
+	return attachmentValid
+}
+
+// attachDocument store the document uploaded in the resource form and record its hash on the resource
+func (c *Controller) attachDocument(r *http.Request, u *fabric.User, resourceID string) error {
+	document, header, err := r.FormFile("document")
+	if err != nil {
+		return fmt.Errorf("the document to attach is missing: %v", err)
+	}
+	defer document.Close()
+
+	name := filepath.Base(header.Filename)
+	err = model.Validate(model.KindName, name)
+	if err != nil {
+		return err
+	}
+
+	// The type is detected from the content rather than trusted from the browser
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(document, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("unable to read the document: %v", err)
+	}
+	mimeType := http.DetectContentType(sniff[:n])
+	_, err = document.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("unable to read the document: %v", err)
+	}
+
+	hash, size, err := c.Attachments.put(document)
+	if err != nil {
+		return err
+	}
+	_, err = u.UpdateAttach(resourceID, hash, name, size, mimeType, formRevision(r, resourceID))
+	return err
+}
+
+// AttachmentHandler controller that allow to download a document attached to a resource
+// The document is only served if it still matches the hash recorded in the ledger.
+func (c *Controller) AttachmentHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		// Check that the user connected is allowed to see the resource details, else return to the home page
+		if !permissions(u)["resource"] {
+			http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
+			return
+		}
+
+		resourceID := r.URL.Query().Get("id")
+		hash := r.URL.Query().Get("hash")
+		if model.Validate(model.KindHash, hash) != nil || hash == "" {
+			http.Error(w, "The hash of the document is invalid", http.StatusBadRequest)
+			return
+		}
+
+		resource, _, err := u.QueryResource(resourceID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resource detail from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		var attachment *model.Attachment
+		found := false
+		if resource != nil {
+			attachment, found = resource.FindAttachment(hash)
+		}
+		if !found {
+			http.Error(w, "The document is not attached to the resource", http.StatusNotFound)
+			return
+		}
+
+		switch c.Attachments.check(hash) {
+		case attachmentMissing:
+			http.Error(w, "The document is missing from the attachment store", http.StatusNotFound)
+			return
+		case attachmentMismatch:
+			http.Error(w, "The document stored doesn't match the hash recorded in the ledger", http.StatusConflict)
+			return
+		}
+
+		document, err := os.Open(c.Attachments.path(hash))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to read the document: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer document.Close()
+
+		mimeType := attachment.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+		http.ServeContent(w, r, attachment.Name, attachment.Time, document)
+	})
+}
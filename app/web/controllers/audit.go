@@ -0,0 +1,131 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"time"
+)
+
+// auditTimeLayout layout of the times of the time range sent by the audit form (HTML datetime-local input)
+const auditTimeLayout = "2006-01-02T15:04"
+
+// AuditHandler controller that allow to see the audit log of the updates made in the ledger and export it as CSV
+func (c *Controller) AuditHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		data := &struct {
+			Error    string
+			Actor    string
+			Action   string
+			From     string
+			To       string
+			Actions  []string
+			Entries  []model.AuditEntry
+			Names    map[string]string
+			Can      map[string]bool
+			Username string
+		}{
+			Actor:    formInput(r, "actor"),
+			Action:   formInput(r, "action"),
+			From:     r.FormValue("from"),
+			To:       r.FormValue("to"),
+			Names:    make(map[string]string),
+			Can:      permissions(u),
+			Username: u.Username,
+		}
+		for _, action := range model.Actions {
+			if action.Write {
+				data.Actions = append(data.Actions, action.Name)
+			}
+		}
+
+		if !data.Can["audit-log"] {
+			w.WriteHeader(http.StatusForbidden)
+			data.Error = "You are not allowed to see the audit log."
+			renderTemplate(w, r, "audit.gohtml", data)
+			return
+		}
+
+		var from, to time.Time
+		var err error
+		if data.From != "" {
+			from, err = time.ParseInLocation(auditTimeLayout, data.From, time.Local)
+		}
+		if err == nil && data.To != "" {
+			to, err = time.ParseInLocation(auditTimeLayout, data.To, time.Local)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			data.Error = "The time range is not valid."
+			renderTemplate(w, r, "audit.gohtml", data)
+			return
+		}
+		data.Error = validateInputs(w, []input{{model.KindActorID, data.Actor}, {model.KindID, data.Action}})
+		if data.Error != "" {
+			renderTemplate(w, r, "audit.gohtml", data)
+			return
+		}
+
+		data.Entries, err = u.QueryAuditLog(data.Actor, data.Action, from, to)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve the audit log from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+
+		consumers, err := u.QueryConsumers()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, consumer := range consumers {
+			data.Names[consumer.ID] = consumer.Name
+		}
+
+		if r.FormValue("format") == "csv" {
+			writeAuditCSV(w, data.Entries, data.Names)
+			return
+		}
+
+		renderTemplate(w, r, "audit.gohtml", data)
+	})
+}
+
+// writeAuditCSV write the audit entries given as a CSV file to download
+func writeAuditCSV(w http.ResponseWriter, entries []model.AuditEntry, names map[string]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.csv\"", time.Now().Format("20060102-150405")))
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "actor", "actor name", "actor type", "action", "resource", "transaction"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.Time.Format(time.RFC3339Nano),
+			entry.Actor,
+			names[entry.Actor],
+			entry.ActorType,
+			entry.Action,
+			entry.Resource,
+			entry.Transaction,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Printf("Unable to write the audit log as CSV: %v\n", err)
+	}
+}
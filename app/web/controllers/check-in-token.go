@@ -0,0 +1,180 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkInTokenSize size in bytes of the random part of a check-in token
+const checkInTokenSize = 32
+
+// CheckInTokens tokens allowing the scripts of the users to check in on their behalf, without their password
+// A token only grant the check-in (see CheckInHandler) and a user has at most one token: generating a new one
+// revoke the previous one. Only the SHA-256 hash of the tokens is stored, in a JSON file, so that they survive
+// a restart of the application.
+type CheckInTokens struct {
+	sync.Mutex
+	File string
+}
+
+// checkInToken owner of a check-in token, with the time the token was generated
+type checkInToken struct {
+	Username  string    `json:"username"`
+	Org       string    `json:"org"`
+	Generated time.Time `json:"generated"`
+}
+
+// load internal method that read the tokens stored, by hash
+func (s *CheckInTokens) load() (map[string]checkInToken, error) {
+	tokens := make(map[string]checkInToken)
+	tokensAsByte, err := ioutil.ReadFile(s.File)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the check-in tokens: %v", err)
+	}
+	err = json.Unmarshal(tokensAsByte, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert the check-in tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// save internal method that write the tokens, the file is replaced at once so that it is never partially written
+func (s *CheckInTokens) save(tokens map[string]checkInToken) error {
+	tokensAsByte, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("unable to convert the check-in tokens: %v", err)
+	}
+	dir := filepath.Dir(s.File)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("unable to create the directory of the check-in tokens: %v", err)
+	}
+	temporary, err := ioutil.TempFile(dir, "check-in-tokens-")
+	if err != nil {
+		return fmt.Errorf("unable to write the check-in tokens: %v", err)
+	}
+	defer os.Remove(temporary.Name())
+	_, err = temporary.Write(tokensAsByte)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the check-in tokens: %v", err)
+	}
+	err = os.Rename(temporary.Name(), s.File)
+	if err != nil {
+		return fmt.Errorf("unable to write the check-in tokens: %v", err)
+	}
+	return nil
+}
+
+// hashCheckInToken internal function that compute the hash a token is stored under
+func hashCheckInToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// withoutUser internal function that remove the token of a user from the tokens
+func withoutUser(tokens map[string]checkInToken, u *fabric.User) {
+	for hash, token := range tokens {
+		if userKey(token.Username, token.Org) == userKey(u.Username, userOrg(u)) {
+			delete(tokens, hash)
+		}
+	}
+}
+
+// generate create a new token for the user, its previous token is revoked
+// The token is returned once, it can't be retrieved afterwards.
+func (s *CheckInTokens) generate(u *fabric.User) (string, error) {
+	// The user is logged in its organization when checking in with the token
+	if userOrg(u) == "" {
+		return "", fmt.Errorf("the organization of the user is unknown")
+	}
+	random := make([]byte, checkInTokenSize)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate the check-in token: %v", err)
+	}
+	token := hex.EncodeToString(random)
+
+	s.Lock()
+	defer s.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	withoutUser(tokens, u)
+	tokens[hashCheckInToken(token)] = checkInToken{Username: u.Username, Org: userOrg(u), Generated: time.Now().UTC()}
+	err = s.save(tokens)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revoke delete the token of the user, if it has one
+func (s *CheckInTokens) revoke(u *fabric.User) error {
+	s.Lock()
+	defer s.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	withoutUser(tokens, u)
+	return s.save(tokens)
+}
+
+// generated retrieve the time the token of the user was generated, nil if the user has no token
+func (s *CheckInTokens) generated(u *fabric.User) (*time.Time, error) {
+	s.Lock()
+	defer s.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if userKey(token.Username, token.Org) == userKey(u.Username, userOrg(u)) {
+			generated := token.Generated
+			return &generated, nil
+		}
+	}
+	return nil, nil
+}
+
+// owner retrieve the user the token given belongs to, false if the token is unknown or revoked
+func (s *CheckInTokens) owner(token string) (checkInToken, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return checkInToken{}, false, err
+	}
+	owner, found := tokens[hashCheckInToken(token)]
+	return owner, found, nil
+}
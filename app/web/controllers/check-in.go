@@ -0,0 +1,168 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checkInResponse response of a check-in to the API clients
+type checkInResponse struct {
+	Resource    string `json:"resource"`
+	Transaction string `json:"transaction,omitempty"`
+	Block       uint64 `json:"block,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// CheckInHandler controller that allow the holder of a resource to show that it still uses it
+// The check-in can be sent periodically by a script: it gets a JSON response if it accepts "application/json".
+// A script authenticates with the check-in token of the user ("Authorization: Bearer <token>", see CheckInTokens)
+// rather than with its password, the token only grants the check-in and the user can revoke it from the page.
+func (c *Controller) CheckInHandler() func(http.ResponseWriter, *http.Request) {
+	withPassword := c.basicAuth(c.checkIn)
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(auth) != 2 || auth[0] != "Bearer" {
+			withPassword(w, r)
+			return
+		}
+
+		owner, found, err := c.CheckInTokens.owner(auth[1])
+		if err != nil || !found {
+			http.Error(w, "authorization failed", http.StatusUnauthorized)
+			return
+		}
+		u, err := c.Fabric.LogEnrolledUser(owner.Org, owner.Username)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("authorization failed with error: %v", err), http.StatusUnauthorized)
+			return
+		}
+		c.checkInAPI(w, r, u)
+	}
+}
+
+// checkInAPI check in the resource posted by a script and answer in JSON
+func (c *Controller) checkInAPI(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+	if !permissions(u)["check-in"] {
+		http.Error(w, "The user is not allowed to check in", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "A check-in must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	response := checkInResponse{Resource: r.FormValue("resource")}
+	status := http.StatusOK
+	transaction, err := u.UpdateCheckIn(response.Resource)
+	if err != nil {
+		status = errorStatus(err)
+		response.Error = errorMessage(err)
+	} else {
+		response.Transaction = transaction.ID
+		response.Block = transaction.Block
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkIn internal method that serve the check-in page, or the API to the users authenticated by their password
+func (c *Controller) checkIn(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		c.checkInAPI(w, r, u)
+		return
+	}
+
+	// Check that the user connected is allowed to check in, else return to the resources page
+	if !permissions(u)["check-in"] {
+		http.Redirect(w, r, "/resources", http.StatusTemporaryRedirect)
+		return
+	}
+
+	preSelectedResource := r.URL.Query().Get("id")
+
+	data := &struct {
+		Error               string
+		Success             bool
+		Response            bool
+		PreSelectedResource string
+		Resources           []model.Resource
+		Transaction         *fabric.Transaction
+		Token               string
+		TokenGenerated      *time.Time
+		TokenRevoked        bool
+		Username            string
+	}{
+		Error:               "",
+		Success:             false,
+		Response:            false,
+		PreSelectedResource: preSelectedResource,
+		Resources:           []model.Resource{},
+		Username:            u.Username,
+	}
+	if r.FormValue(formSubmittedKey) == formSubmittedValue {
+		var err error
+		switch action := r.FormValue("action"); action {
+		case "", "check-in":
+			var transaction *fabric.Transaction
+			transaction, err = u.UpdateCheckIn(r.FormValue("resource"))
+			if err == nil {
+				data.Transaction = transaction
+				c.receipts.keep(u, transaction)
+			}
+		case "generate-token":
+			data.Token, err = c.CheckInTokens.generate(u)
+		case "revoke-token":
+			err = c.CheckInTokens.revoke(u)
+			data.TokenRevoked = err == nil
+		default:
+			err = fmt.Errorf("unknown check-in action '%s'", action)
+		}
+		if err != nil {
+			data.Error = transactionFailed(w, err)
+		} else {
+			data.Success = true
+		}
+		data.Response = true
+	}
+
+	var err error
+	data.TokenGenerated, err = c.CheckInTokens.generated(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The companions are checked in with the resource they are acquired with
+	resources, err := u.QueryResources(model.ResourcesFilterOnlyUnavailable)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+		return
+	}
+	for _, resource := range resources {
+		if resource.Bundle == "" {
+			data.Resources = append(data.Resources, resource)
+		}
+	}
+
+	renderTemplate(w, r, "check-in.gohtml", data)
+}
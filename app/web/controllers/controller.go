@@ -18,6 +18,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
 	"html/template"
 	"net/http"
 	"os"
@@ -31,9 +32,24 @@ const (
 	formSubmittedValue = "true"
 )
 
+// templateFuncs functions available in the templates, they expose the validation rules of the chaincode
+// so that the forms validate the inputs client-side with the same rules as server-side
+var templateFuncs = template.FuncMap{
+	"maxLength": func(kind string) int {
+		return model.ValidationRules[kind].MaxLength
+	},
+	"pattern": func(kind string) string {
+		return model.ValidationRules[kind].Pattern
+	},
+	"priority": model.PriorityName,
+}
+
 // Controller struct use to store a Fabric SDK instance and serve web pages
 type Controller struct {
-	Fabric *fabric.Setup
+	Fabric        *fabric.Setup
+	Attachments   AttachmentStore
+	CheckInTokens CheckInTokens
+	receipts      receiptStore
 }
 
 // basicAuth used to check the authentication (using basic auth) and retrieve the blockchain user
@@ -61,7 +77,13 @@ func (c *Controller) basicAuth(pass func(http.ResponseWriter, *http.Request, *fa
 			return
 		}
 
-		u, err := c.Fabric.LogUser(pair[0], pair[1])
+		// The username can be suffixed by the organization of the user ("user@org"), else the default one is used
+		username, orgID := pair[0], ""
+		if i := strings.LastIndex(username, "@"); i >= 0 {
+			username, orgID = username[:i], username[i+1:]
+		}
+
+		u, err := c.Fabric.LogUser(orgID, username, pair[1])
 		if err != nil {
 			http.Error(w, fmt.Sprintf("authorization failed with error: %v", err), http.StatusUnauthorized)
 			return
@@ -71,6 +93,119 @@ func (c *Controller) basicAuth(pass func(http.ResponseWriter, *http.Request, *fa
 	}
 }
 
+// permissions retrieve for every action of the chaincode whether the user connected is allowed to perform it
+func permissions(u *fabric.User) map[string]bool {
+	can := make(map[string]bool)
+	actor, err := u.QueryActor()
+	if err != nil {
+		return can
+	}
+	for action := range model.Permissions {
+		can[action] = model.IsAllowed(actor.Type, action)
+	}
+	return can
+}
+
+// userOrg retrieve the ID of the organization of a user, empty if it is unknown
+func userOrg(u *fabric.User) string {
+	if u.Org == nil {
+		return ""
+	}
+	return u.Org.ID
+}
+
+// userKey identify a user, the same username may exist in several organizations
+func userKey(username string, orgID string) string {
+	if orgID == "" {
+		return username
+	}
+	return username + "@" + orgID
+}
+
+// formRevision retrieve the revision of a resource rendered in a form, in order to detect concurrent updates
+func formRevision(r *http.Request, resourceID string) string {
+	return r.FormValue("revision-" + resourceID)
+}
+
+// errorStatus retrieve the HTTP status code matching an error returned by the blockchain
+func errorStatus(err error) int {
+	switch fabric.ErrorCode(err) {
+	case model.ErrorNotFound:
+		return http.StatusNotFound
+	case model.ErrorNotAvailable, model.ErrorConflict:
+		return http.StatusConflict
+	case model.ErrorForbidden:
+		return http.StatusForbidden
+	case model.ErrorInvalidArgument:
+		return http.StatusBadRequest
+	case model.ErrorNoCredit:
+		return http.StatusPaymentRequired
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMessage build a friendly message from an error returned by the blockchain
+func errorMessage(err error) string {
+	chaincodeError, ok := err.(*model.Error)
+	if !ok {
+		return err.Error()
+	}
+	switch chaincodeError.Code {
+	case model.ErrorNotFound:
+		return fmt.Sprintf("Not found, it may have been deleted. %s", chaincodeError.Message)
+	case model.ErrorNotAvailable:
+		return fmt.Sprintf("Not available right now. %s", chaincodeError.Message)
+	case model.ErrorForbidden:
+		return fmt.Sprintf("You are not allowed to do that. %s", chaincodeError.Message)
+	case model.ErrorInvalidArgument:
+		return fmt.Sprintf("The request is invalid. %s", chaincodeError.Message)
+	case model.ErrorNoCredit:
+		return fmt.Sprintf("Not enough credits. %s", chaincodeError.Message)
+	case model.ErrorConflict:
+		if fabric.IsRevisionConflict(err) {
+			return "The resource changed since you loaded it, check its new state and retry."
+		}
+		return fmt.Sprintf("The request conflicts with the current state. %s", chaincodeError.Message)
+	default:
+		return chaincodeError.Error()
+	}
+}
+
+// transactionFailed set the HTTP status code of a transaction that failed and build the error message shown
+func transactionFailed(w http.ResponseWriter, err error) string {
+	w.WriteHeader(errorStatus(err))
+	if code := fabric.ErrorCode(err); code != "" && code != model.ErrorInternal {
+		return errorMessage(err)
+	}
+	return fmt.Sprintf("Unable to make the transaction in the ledger: %v", err)
+}
+
+// formInput retrieve the value of a form field given by the user, without its leading and trailing spaces
+func formInput(r *http.Request, field string) string {
+	return strings.TrimSpace(r.FormValue(field))
+}
+
+// input value given by the user with its kind (see model.ValidationRules)
+type input struct {
+	kind  string
+	value string
+}
+
+// validateInputs check the values given by the user against the validation rules of the chaincode, in the order of
+// the form. If a value is invalid, the HTTP status code is set and the message shown for the first one is returned,
+// else an empty message.
+func validateInputs(w http.ResponseWriter, inputs []input) string {
+	for _, in := range inputs {
+		err := model.Validate(in.kind, in.value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return fmt.Sprintf("The request is invalid: %v.", err)
+		}
+	}
+	return ""
+}
+
 // LogoutHandler handler to disconnect the user (using basic auth)
 func (c *Controller) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
@@ -98,7 +233,7 @@ func renderTemplate(w http.ResponseWriter, r *http.Request, templateName string,
 		return
 	}
 
-	resultTemplate, err := template.ParseFiles(tp, lp)
+	resultTemplate, err := template.New(filepath.Base(tp)).Funcs(templateFuncs).ParseFiles(tp, lp)
 	if err != nil {
 		// Log the detailed error
 		fmt.Println(err.Error())
@@ -0,0 +1,99 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"strconv"
+)
+
+// CreditsHandler controller that allow to see the credits of a consumer with their movements, and to allocate credits
+func (c *Controller) CreditsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		can := permissions(u)
+
+		// Check that the user connected is allowed to see credits, else return to the home page
+		if !can["balance"] {
+			http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
+			return
+		}
+
+		data := &struct {
+			Error     string
+			Success   bool
+			Response  bool
+			Consumer  string
+			Consumers []model.Consumer
+			Account   *model.Account
+			Movements []model.CreditMovement
+			Can       map[string]bool
+			Username  string
+		}{
+			Error:     "",
+			Success:   false,
+			Response:  false,
+			Consumer:  r.FormValue("consumer"),
+			Consumers: []model.Consumer{},
+			Can:       can,
+			Username:  u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue && r.FormValue("action") == "allocate-credits" {
+			amount, err := strconv.ParseInt(formInput(r, "amount"), 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				data.Error = fmt.Sprintf("The request is invalid: the amount '%s' is not a number of credits.", formInput(r, "amount"))
+			} else {
+				_, err = u.UpdateAllocateCredits(data.Consumer, amount)
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+				}
+			}
+			data.Response = true
+		}
+
+		// A consumer only see its own credits, the others choose the consumer
+		consumer := can["acquire"]
+		if !consumer {
+			var err error
+			data.Consumers, err = u.QueryConsumers()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+		}
+
+		if consumer || data.Consumer != "" {
+			var err error
+			data.Account, err = u.QueryBalance(data.Consumer)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve the balance from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			data.Movements, err = u.QueryCreditMovements(data.Consumer)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve the credit movements from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+		}
+
+		renderTemplate(w, r, "credits.gohtml", data)
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"time"
+)
+
+// delegationExpirationLayout is the layout of the expiration sent by the grant form (HTML datetime-local input)
+const delegationExpirationLayout = "2006-01-02T15:04"
+
+// DelegationsHandler controller that allow a consumer to see and manage the delegations of acquisition rights
+func (c *Controller) DelegationsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		can := permissions(u)
+
+		data := &struct {
+			Error     string
+			Success   bool
+			Response  bool
+			Granted   model.Delegations
+			Received  model.Delegations
+			Consumers []model.Consumer
+			Now       time.Time
+			Can       map[string]bool
+			Username  string
+		}{
+			Error:     "",
+			Success:   false,
+			Response:  false,
+			Granted:   model.Delegations{},
+			Received:  model.Delegations{},
+			Consumers: []model.Consumer{},
+			Now:       time.Now(),
+			Can:       can,
+			Username:  u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			var err error
+			delegateID := r.FormValue("delegate")
+			switch action := r.FormValue("action"); action {
+			case "grant-delegation":
+				var expiration time.Time
+				expiration, err = time.ParseInLocation(delegationExpirationLayout, r.FormValue("expiration"), time.Local)
+				if err != nil {
+					err = fmt.Errorf("invalid expiration '%s', expected format is 'YYYY-MM-DDTHH:MM'", r.FormValue("expiration"))
+					break
+				}
+				_, err = u.UpdateGrantDelegation(delegateID, expiration)
+			case "revoke-delegation":
+				_, err = u.UpdateRevokeDelegation(delegateID)
+			default:
+				err = fmt.Errorf("unknown delegation action '%s'", action)
+			}
+			if err != nil {
+				data.Error = transactionFailed(w, err)
+			} else {
+				data.Success = true
+			}
+			data.Response = true
+		}
+
+		if can["delegations"] {
+			consumer, err := u.QueryConsumer()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve consumer from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			delegations, err := u.QueryDelegations()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve delegations from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, delegation := range delegations {
+				if delegation.Delegator == consumer.ID {
+					data.Granted = append(data.Granted, delegation)
+				} else {
+					data.Received = append(data.Received, delegation)
+				}
+			}
+
+			consumers, err := u.QueryConsumers()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, other := range consumers {
+				if other.ID != consumer.ID {
+					data.Consumers = append(data.Consumers, other)
+				}
+			}
+		}
+
+		renderTemplate(w, r, "delegations.gohtml", data)
+	})
+}
@@ -25,9 +25,8 @@ import (
 func (c *Controller) DeleteResourceHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		// Check that the user connected is an admin, else return to the resources page
-		_, err := u.QueryAdmin()
-		if err != nil {
+		// Check that the user connected is allowed to delete a resource, else return to the resources page
+		if !permissions(u)["delete"] {
 			http.Redirect(w, r, "/resources", http.StatusTemporaryRedirect)
 			return
 		}
@@ -51,9 +50,9 @@ func (c *Controller) DeleteResourceHandler() func(http.ResponseWriter, *http.Req
 		}
 		if r.FormValue(formSubmittedKey) == formSubmittedValue {
 			resourceID := r.FormValue("resource")
-			err = u.UpdateDelete(resourceID)
+			_, err := u.UpdateDelete(resourceID, formRevision(r, resourceID))
 			if err != nil {
-				data.Error = fmt.Sprintf("Unable to make the transaction in the ledger: %v", err)
+				data.Error = transactionFailed(w, err)
 			} else {
 				data.Success = true
 			}
@@ -62,7 +61,7 @@ func (c *Controller) DeleteResourceHandler() func(http.ResponseWriter, *http.Req
 
 		resources, err := u.QueryResources(model.ResourcesFilterOnlyAvailable)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
 			return
 		}
 		data.Resources = resources
@@ -19,41 +19,122 @@ import (
 	"github.com/chainHero/resource-manager/app/fabric"
 	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // HomeHandler controller that allow to get the home page
 func (c *Controller) HomeHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		resources, err := u.QueryResources(model.ResourcesFilterAll)
+		can := permissions(u)
+
+		// The admin resume the migration of the ledger from the home page
+		migrationError := ""
+		migrationResponse := false
+		if r.FormValue(formSubmittedKey) == formSubmittedValue && r.FormValue("action") == "migrate" && can["migrate"] {
+			_, err := u.UpdateMigrate(0)
+			if err != nil {
+				migrationError = transactionFailed(w, err)
+			}
+			migrationResponse = true
+		}
+
+		// The counts are maintained by the chaincode, the resources are not listed to count them
+		stats, err := u.QueryStats()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Unable to retrieve the statistics from the ledger: %s", errorMessage(err)), errorStatus(err))
 			return
 		}
 
-		var resourcesCount uint64
-		var resourcesAvailableCount uint64
-		var resourcesUnavailableCount uint64
-
-		for _, resource := range resources {
-			resourcesCount++
-			if resource.Available {
-				resourcesAvailableCount++
-			} else {
-				resourcesUnavailableCount++
-			}
+		locations, err := u.QueryLocations()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve locations from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
 		}
 
 		data := &struct {
-			Username                  string
-			ResourcesCount            uint64
-			ResourcesAvailableCount   uint64
-			ResourcesUnavailableCount uint64
+			Error         string
+			Success       bool
+			Response      bool
+			Schema        *model.Schema
+			Username      string
+			Stats         *model.Stats
+			Locations     model.Locations
+			TeamHoldings  []model.Resource
+			TeamNames     map[string]string
+			Account       *model.Account
+			StaleHoldings []model.Resource
+			StaleWindow   string
 		}{
-			Username:                  u.Username,
-			ResourcesCount:            resourcesCount,
-			ResourcesAvailableCount:   resourcesAvailableCount,
-			ResourcesUnavailableCount: resourcesUnavailableCount,
+			Error:        migrationError,
+			Success:      migrationResponse && migrationError == "",
+			Response:     migrationResponse,
+			Username:     u.Username,
+			Stats:        stats,
+			Locations:    locations,
+			TeamHoldings: []model.Resource{},
+			TeamNames:    make(map[string]string),
+		}
+
+		// The admin dashboard warn when migrations of the ledger are pending
+		if can["schema"] {
+			data.Schema, err = u.QuerySchema()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve the schema from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+		}
+
+		// The admin dashboard highlight the holdings without check-in within the window chosen (in hours)
+		if can["stale-holdings"] {
+			window := model.StaleHoldingWindow
+			data.StaleWindow = r.FormValue("stale-window")
+			if data.StaleWindow != "" {
+				hours, err := strconv.Atoi(data.StaleWindow)
+				if err != nil || hours <= 0 {
+					http.Error(w, fmt.Sprintf("The window '%s' is not a positive number of hours", data.StaleWindow), http.StatusBadRequest)
+					return
+				}
+				window = time.Duration(hours) * time.Hour
+			}
+			data.StaleWindow = strconv.Itoa(int(window / time.Hour))
+			data.StaleHoldings, err = u.QueryStaleHoldings(window)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve the stale holdings from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+		}
+
+		// The consumer dashboard show the credits left
+		if can["balance"] && can["acquire"] {
+			data.Account, err = u.QueryBalance("")
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve the balance from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+		}
+
+		// The consumer dashboard show the resources held by its teams
+		if can["renew"] {
+			teams, err := u.QueryTeams()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve teams from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, team := range teams {
+				data.TeamNames[team.ID] = team.Name
+			}
+			resources, err := u.QueryResources(model.ResourcesFilterOnlyUnavailable)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, resource := range resources {
+				if !resource.Available && data.TeamNames[resource.Team] != "" {
+					data.TeamHoldings = append(data.TeamHoldings, resource)
+				}
+			}
 		}
 		renderTemplate(w, r, "home.gohtml", data)
 	})
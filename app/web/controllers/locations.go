@@ -0,0 +1,77 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+)
+
+// LocationsHandler controller that allow to see the location tree (sites, buildings and rooms) and to manage it
+func (c *Controller) LocationsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		data := &struct {
+			Error     string
+			Success   bool
+			Response  bool
+			Locations model.Locations
+			Kinds     []string
+			Can       map[string]bool
+			Username  string
+		}{
+			Error:     "",
+			Success:   false,
+			Response:  false,
+			Locations: model.Locations{},
+			Kinds:     model.LocationKinds,
+			Can:       permissions(u),
+			Username:  u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			locationID := formInput(r, "location")
+			name := formInput(r, "name")
+			data.Error = validateInputs(w, []input{{model.KindID, locationID}, {model.KindName, name}})
+			if data.Error == "" {
+				var err error
+				switch action := r.FormValue("action"); action {
+				case "add-location":
+					_, err = u.UpdateAddLocation(locationID, name, r.FormValue("kind"), r.FormValue("parent"))
+				case "delete-location":
+					_, err = u.UpdateDeleteLocation(locationID)
+				default:
+					err = fmt.Errorf("unknown location action '%s'", action)
+				}
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+				}
+			}
+			data.Response = true
+		}
+
+		locations, err := u.QueryLocations()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve locations from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Locations = locations
+
+		renderTemplate(w, r, "locations.gohtml", data)
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"time"
+)
+
+// missionTimeLayout layout of the start and end sent by the mission form (HTML datetime-local input)
+const missionTimeLayout = "2006-01-02T15:04"
+
+// MissionsHandler controller that allow to see the missions of the organization, to add and to close them
+func (c *Controller) MissionsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		can := permissions(u)
+
+		data := &struct {
+			Error    string
+			Success  bool
+			Response bool
+			Action   string
+			Missions []model.Mission
+			Closable map[string]bool
+			Names    map[string]string
+			Can      map[string]bool
+			Username string
+		}{
+			Error:    "",
+			Success:  false,
+			Response: false,
+			Action:   r.FormValue("action"),
+			Missions: []model.Mission{},
+			Closable: make(map[string]bool),
+			Names:    make(map[string]string),
+			Can:      can,
+			Username: u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			var err error
+			switch data.Action {
+			case "add-mission":
+				missionID := formInput(r, "mission")
+				title := formInput(r, "title")
+				data.Error = validateInputs(w, []input{{model.KindID, missionID}, {model.KindName, title}})
+				if data.Error == "" {
+					err = addMission(u, r, missionID, title)
+				}
+			case "close-mission":
+				_, err = u.UpdateCloseMission(r.FormValue("mission"))
+			default:
+				err = fmt.Errorf("unknown mission action '%s'", data.Action)
+			}
+			if err != nil {
+				data.Error = transactionFailed(w, err)
+			} else if data.Error == "" {
+				data.Success = true
+			}
+			data.Response = true
+		}
+
+		missions, err := u.QueryMissions()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve missions from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Missions = missions
+
+		actor, err := u.QueryActor()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve actor from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, mission := range missions {
+			data.Closable[mission.ID] = isMissionClosable(can, actor, &mission)
+		}
+
+		data.Names, err = consumerNames(u)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+
+		renderTemplate(w, r, "missions.gohtml", data)
+	})
+}
+
+// MissionHandler controller that allow to see a mission with the resources acquired for it and its timeline
+func (c *Controller) MissionHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		missionID := r.URL.Query().Get("id")
+		if missionID == "" {
+			http.Redirect(w, r, "/missions", http.StatusTemporaryRedirect)
+			return
+		}
+
+		can := permissions(u)
+
+		data := &struct {
+			Error    string
+			Success  bool
+			Response bool
+			Detail   *model.MissionDetail
+			Closable bool
+			Names    map[string]string
+			Can      map[string]bool
+			Username string
+		}{
+			Error:    "",
+			Success:  false,
+			Response: false,
+			Names:    make(map[string]string),
+			Can:      can,
+			Username: u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			_, err := u.UpdateCloseMission(missionID)
+			if err != nil {
+				data.Error = transactionFailed(w, err)
+			} else {
+				data.Success = true
+			}
+			data.Response = true
+		}
+
+		detail, err := u.QueryMission(missionID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve the mission from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Detail = detail
+
+		actor, err := u.QueryActor()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve actor from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Closable = isMissionClosable(can, actor, &detail.Mission)
+
+		data.Names, err = consumerNames(u)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+
+		renderTemplate(w, r, "mission.gohtml", data)
+	})
+}
+
+// addMission add the mission described by the mission form
+func addMission(u *fabric.User, r *http.Request, missionID string, title string) error {
+	start, err := time.ParseInLocation(missionTimeLayout, r.FormValue("start"), time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid start '%s', expected format is 'YYYY-MM-DDTHH:MM'", r.FormValue("start"))
+	}
+	var end *time.Time
+	if r.FormValue("end") != "" {
+		missionEnd, err := time.ParseInLocation(missionTimeLayout, r.FormValue("end"), time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid end '%s', expected format is 'YYYY-MM-DDTHH:MM'", r.FormValue("end"))
+		}
+		end = &missionEnd
+	}
+	_, err = u.UpdateAddMission(missionID, title, start, end)
+	return err
+}
+
+// consumerNames retrieve the names of the consumers by ID
+func consumerNames(u *fabric.User) (map[string]string, error) {
+	names := make(map[string]string)
+	consumers, err := u.QueryConsumers()
+	if err != nil {
+		return nil, err
+	}
+	for _, consumer := range consumers {
+		names[consumer.ID] = consumer.Name
+	}
+	return names, nil
+}
+
+// isMissionClosable check whether the actor can close the mission, a consumer can only close its own missions
+func isMissionClosable(can map[string]bool, actor *model.Actor, mission *model.Mission) bool {
+	return can["close-mission"] && mission.Status == model.MissionOpen && (actor.Type != model.ActorConsumer || actor.ID == mission.Owner)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"net/http"
+	"sync"
+)
+
+// receiptsKept number of receipts kept for download, the oldest ones are forgotten first
+const receiptsKept = 1000
+
+// receiptStore receipts of the latest transactions, kept in memory until their users download them
+// The store is best-effort: the receipts are lost when the application restarts and the oldest ones are forgotten
+// once the limit is reached, the transaction is then only found in its block.
+type receiptStore struct {
+	sync.Mutex
+	receipts map[string]storedReceipt
+	order    []string
+}
+
+// storedReceipt receipt of a transaction with the user that made it (see userKey)
+type storedReceipt struct {
+	owner   string
+	receipt *fabric.Receipt
+}
+
+// keep store the receipt of a transaction made by the user, if the transaction has one
+func (s *receiptStore) keep(u *fabric.User, transaction *fabric.Transaction) {
+	if transaction == nil || transaction.Receipt == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.receipts == nil {
+		s.receipts = make(map[string]storedReceipt)
+	}
+	if len(s.order) >= receiptsKept {
+		delete(s.receipts, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.receipts[transaction.ID] = storedReceipt{owner: userKey(u.Username, userOrg(u)), receipt: transaction.Receipt}
+	s.order = append(s.order, transaction.ID)
+}
+
+// get retrieve the receipt of a transaction made by the user
+func (s *receiptStore) get(u *fabric.User, transactionID string) (*fabric.Receipt, bool) {
+	s.Lock()
+	defer s.Unlock()
+	stored, found := s.receipts[transactionID]
+	if !found || stored.owner != userKey(u.Username, userOrg(u)) {
+		return nil, false
+	}
+	return stored.receipt, true
+}
+
+// ReceiptHandler controller that allow to download the receipt of a transaction made by the user
+// The receipt can be checked offline with the verify-receipt command of the application.
+func (c *Controller) ReceiptHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		transactionID := r.URL.Query().Get("tx")
+		receipt, found := c.receipts.get(u, transactionID)
+		if !found {
+			http.Error(w, "The receipt of the transaction is not available, only the receipts of your latest transactions are kept", http.StatusNotFound)
+			return
+		}
+
+		receiptAsByte, err := json.MarshalIndent(receipt, "", "  ")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to convert the receipt: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"receipt-%s.json\"", transactionID))
+		w.Write(receiptAsByte)
+	})
+}
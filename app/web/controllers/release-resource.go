@@ -19,12 +19,19 @@ import (
 	"github.com/chainHero/resource-manager/app/fabric"
 	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
+	"strconv"
 )
 
 // ReleaseResourceHandler controller that allow to release a resource
 func (c *Controller) ReleaseResourceHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
+		// Check that the user connected is allowed to release a resource, else return to the resources page
+		if !permissions(u)["release"] {
+			http.Redirect(w, r, "/resources", http.StatusTemporaryRedirect)
+			return
+		}
+
 		preSelectedResource := r.URL.Query().Get("id")
 
 		data := &struct {
@@ -33,6 +40,8 @@ func (c *Controller) ReleaseResourceHandler() func(http.ResponseWriter, *http.Re
 			Response            bool
 			PreSelectedResource string
 			Resources           []model.Resource
+			Conditions          []string
+			Transaction         *fabric.Transaction
 			Username            string
 		}{
 			Error:               "",
@@ -40,22 +49,34 @@ func (c *Controller) ReleaseResourceHandler() func(http.ResponseWriter, *http.Re
 			Response:            false,
 			PreSelectedResource: preSelectedResource,
 			Resources:           []model.Resource{},
+			Conditions:          model.Conditions,
 			Username:            u.Username,
 		}
 		if r.FormValue(formSubmittedKey) == formSubmittedValue {
 			resourceID := r.FormValue("resource")
-			err := u.UpdateRelease(resourceID)
+			report, err := formConditionReport(r)
 			if err != nil {
-				data.Error = fmt.Sprintf("Unable to make the transaction in the ledger: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				data.Error = fmt.Sprintf("The request is invalid: %v.", err)
 			} else {
-				data.Success = true
+				data.Error = validateInputs(w, []input{{model.KindDescription, formInput(r, "notes")}})
+			}
+			if data.Error == "" {
+				transaction, err := u.UpdateRelease(resourceID, formRevision(r, resourceID), report)
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+					data.Transaction = transaction
+					c.receipts.keep(u, transaction)
+				}
 			}
 			data.Response = true
 		}
 
 		resources, err := u.QueryResources(model.ResourcesFilterOnlyUnavailable)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
 			return
 		}
 		data.Resources = resources
@@ -63,3 +84,20 @@ func (c *Controller) ReleaseResourceHandler() func(http.ResponseWriter, *http.Re
 		renderTemplate(w, r, "release-resource.gohtml", data)
 	})
 }
+
+// formConditionReport retrieve the condition report given in the release form, nil if no condition is given
+func formConditionReport(r *http.Request) (*model.ConditionReport, error) {
+	condition := r.FormValue("condition")
+	if condition == "" {
+		return nil, nil
+	}
+	report := &model.ConditionReport{Condition: condition, Notes: formInput(r, "notes")}
+	if meter := formInput(r, "meter"); meter != "" {
+		reading, err := strconv.ParseFloat(meter, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meter reading '%s', expected a number", meter)
+		}
+		report.Meter = &reading
+	}
+	return report, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+)
+
+// RenewResourceHandler controller that allow to take over a resource acquired by the user or its team
+func (c *Controller) RenewResourceHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		// Check that the user connected is allowed to renew a resource, else return to the resources page
+		if !permissions(u)["renew"] {
+			http.Redirect(w, r, "/resources", http.StatusTemporaryRedirect)
+			return
+		}
+
+		preSelectedResource := r.URL.Query().Get("id")
+
+		data := &struct {
+			Error               string
+			Success             bool
+			Response            bool
+			PreSelectedResource string
+			Resources           []model.Resource
+			Transaction         *fabric.Transaction
+			Username            string
+		}{
+			Error:               "",
+			Success:             false,
+			Response:            false,
+			PreSelectedResource: preSelectedResource,
+			Resources:           []model.Resource{},
+			Username:            u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			resourceID := r.FormValue("resource")
+			mission := formInput(r, "mission")
+			data.Error = validateInputs(w, []input{{model.KindMission, mission}})
+			if data.Error == "" {
+				transaction, err := u.UpdateRenew(resourceID, mission, formRevision(r, resourceID))
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+					data.Transaction = transaction
+					c.receipts.keep(u, transaction)
+				}
+			}
+			data.Response = true
+		}
+
+		resources, err := u.QueryResources(model.ResourcesFilterOnlyUnavailable)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Resources = resources
+
+		renderTemplate(w, r, "renew-resource.gohtml", data)
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reservationTimeLayout layout of the start and end sent by the reservation form (HTML datetime-local input)
+const reservationTimeLayout = "2006-01-02T15:04"
+
+// reservationUntilLayout layout of the end date of the recurrence sent by the reservation form (HTML date input)
+const reservationUntilLayout = "2006-01-02"
+
+// reservationUpcomingCount number of upcoming occurrences shown for every reservation
+const reservationUpcomingCount = 5
+
+// reservationView reservation with its next occurrences, as shown in the reservations page
+type reservationView struct {
+	model.Reservation
+	Upcoming []model.Occurrence
+	More     int
+}
+
+// ReservationsHandler controller that allow to see, make and cancel the reservations of resources
+func (c *Controller) ReservationsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		can := permissions(u)
+
+		data := &struct {
+			Error        string
+			Success      bool
+			Response     bool
+			ResourceID   string
+			Reservations []reservationView
+			Resources    []model.Resource
+			Teams        map[string]string
+			Names        map[string]string
+			Can          map[string]bool
+			Username     string
+			TimeZone     string
+		}{
+			Error:      "",
+			Success:    false,
+			Response:   false,
+			ResourceID: r.URL.Query().Get("id"),
+			Teams:      make(map[string]string),
+			Names:      make(map[string]string),
+			Can:        can,
+			Username:   u.Username,
+			TimeZone:   localTimeZone(),
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			var err error
+			switch action := r.FormValue("action"); action {
+			case "reserve":
+				err = reserve(u, r)
+			case "cancel-reservation":
+				var occurrence time.Time
+				if r.FormValue("occurrence") != "" {
+					occurrence, err = time.Parse(time.RFC3339, r.FormValue("occurrence"))
+				}
+				if err == nil {
+					_, err = u.UpdateCancelReservation(r.FormValue("resource"), r.FormValue("reservation"), occurrence)
+				}
+			default:
+				err = fmt.Errorf("unknown reservation action '%s'", action)
+			}
+			if err != nil {
+				data.Error = transactionFailed(w, err)
+			} else {
+				data.Success = true
+			}
+			data.Response = true
+		}
+
+		reservations, err := u.QueryReservations(data.ResourceID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve reservations from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		now := time.Now()
+		for _, reservation := range reservations {
+			occurrences, err := reservation.Occurrences()
+			if err != nil {
+				continue
+			}
+			view := reservationView{Reservation: reservation}
+			for _, occurrence := range occurrences {
+				if !occurrence.End.After(now) {
+					continue
+				}
+				if len(view.Upcoming) < reservationUpcomingCount {
+					view.Upcoming = append(view.Upcoming, occurrence)
+				} else {
+					view.More++
+				}
+			}
+			if len(view.Upcoming) > 0 {
+				data.Reservations = append(data.Reservations, view)
+			}
+		}
+
+		data.Resources, err = u.QueryResources(model.ResourcesFilterAll)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+
+		teams, err := u.QueryTeams()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve teams from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, team := range teams {
+			data.Teams[team.ID] = team.Name
+		}
+
+		consumers, err := u.QueryConsumers()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		for _, consumer := range consumers {
+			data.Names[consumer.ID] = consumer.Name
+		}
+
+		renderTemplate(w, r, "reservations.gohtml", data)
+	})
+}
+
+// localTimeZone retrieve the TZID of the time zone of the application, from the TZ variable or else from the zone
+// file linked by /etc/localtime, UTC if it is unknown
+func localTimeZone() string {
+	if timeZone := strings.TrimPrefix(os.Getenv("TZ"), ":"); timeZone != "" {
+		return timeZone
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+			return target[i+len("zoneinfo/"):]
+		}
+	}
+	return "UTC"
+}
+
+// reserve make the reservation described by the reservation form
+// The times are the ones of the time zone given, the recurrence is expanded in it by the chaincode.
+func reserve(u *fabric.User, r *http.Request) error {
+	timeZone := r.FormValue("time-zone")
+	if timeZone == "" {
+		timeZone = localTimeZone()
+	}
+	location, err := (model.Reservation{TimeZone: timeZone}).Location()
+	if err != nil {
+		return fmt.Errorf("invalid time zone '%s', expected a TZID like 'Europe/Paris'", timeZone)
+	}
+
+	start, err := time.ParseInLocation(reservationTimeLayout, r.FormValue("start"), location)
+	if err != nil {
+		return fmt.Errorf("invalid start '%s', expected format is 'YYYY-MM-DDTHH:MM'", r.FormValue("start"))
+	}
+	end, err := time.ParseInLocation(reservationTimeLayout, r.FormValue("end"), location)
+	if err != nil {
+		return fmt.Errorf("invalid end '%s', expected format is 'YYYY-MM-DDTHH:MM'", r.FormValue("end"))
+	}
+
+	var rule string
+	if frequency := r.FormValue("frequency"); frequency != "" {
+		recurrence := model.Recurrence{Frequency: frequency, Interval: 1}
+		if r.FormValue("interval") != "" {
+			recurrence.Interval, err = strconv.Atoi(r.FormValue("interval"))
+			if err != nil || recurrence.Interval < 1 {
+				return fmt.Errorf("invalid interval '%s', expected a positive number", r.FormValue("interval"))
+			}
+		}
+		// The recurrence ends at the end of the day given
+		until, err := time.ParseInLocation(reservationUntilLayout, r.FormValue("until"), location)
+		if err != nil {
+			return fmt.Errorf("invalid end date '%s', expected format is 'YYYY-MM-DD'", r.FormValue("until"))
+		}
+		recurrence.Until = until.AddDate(0, 0, 1).Add(-time.Second).UTC()
+		rule = recurrence.String()
+	}
+
+	_, err = u.UpdateReserve(r.FormValue("resource"), start, end, rule, r.FormValue("team"), timeZone)
+	return err
+}
@@ -19,15 +19,17 @@ import (
 	"github.com/chainHero/resource-manager/app/fabric"
 	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
+	"strconv"
 )
 
 // ResourceHandler controller that allow to see resource details
 func (c *Controller) ResourceHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		// Check that the user connected is an admin, else return to the home page
-		_, err := u.QueryAdmin()
-		if err != nil {
+		can := permissions(u)
+
+		// Check that the user connected is allowed to see the resource details, else return to the home page
+		if !can["resource"] {
 			http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
 			return
 		}
@@ -38,23 +40,123 @@ func (c *Controller) ResourceHandler() func(http.ResponseWriter, *http.Request)
 			return
 		}
 
-		resource, resourcesHistory, err := u.QueryResource(resourceID)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resource detail from the ledger: %v", err), http.StatusInternalServerError)
-			return
-		}
-
 		data := &struct {
+			Error     string
+			Success   bool
+			Response  bool
 			Username  string
 			Resource  *model.Resource
 			Histories model.ResourceHistories
 			IsDeleted bool
+			Orgs      []fabric.Org
+			Endorsing map[string]bool
+			Action    string
+			Resources []model.Resource
+			Bundled   map[string]bool
+			Locations model.Locations
+			Documents map[string]string
+			Can       map[string]bool
 		}{
+			Error:     "",
+			Success:   false,
+			Response:  false,
 			Username:  u.Username,
-			Resource:  resource,
-			Histories: resourcesHistory,
-			IsDeleted: len(resourcesHistory) > 0 && resourcesHistory[0].Deleted,
+			Orgs:      c.Fabric.Orgs,
+			Endorsing: make(map[string]bool),
+			Action:    r.FormValue("action"),
+			Bundled:   make(map[string]bool),
+			Documents: make(map[string]string),
+			Can:       can,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			var err error
+			switch data.Action {
+			case "set-endorsement":
+				_, err = u.UpdateSetEndorsement(resourceID, r.Form["orgs"], formRevision(r, resourceID))
+			case "set-companions":
+				_, err = u.UpdateSetCompanions(resourceID, r.Form["companions"], formRevision(r, resourceID))
+			case "restore":
+				_, err = u.UpdateRestore(resourceID, formRevision(r, resourceID))
+			case "move":
+				_, err = u.UpdateMove(resourceID, r.FormValue("location"), formRevision(r, resourceID))
+			case "set-cost":
+				var perUse, perHour int64
+				perUse, err = formCost(r, "per-use")
+				if err == nil {
+					perHour, err = formCost(r, "per-hour")
+				}
+				if err == nil {
+					_, err = u.UpdateSetCost(resourceID, perUse, perHour, formRevision(r, resourceID))
+				}
+			case "attach":
+				err = c.attachDocument(r, u, resourceID)
+			default:
+				err = fmt.Errorf("unknown resource action '%s'", data.Action)
+			}
+			if err != nil {
+				data.Error = transactionFailed(w, err)
+			} else {
+				data.Success = true
+			}
+			data.Response = true
 		}
+
+		resource, resourcesHistory, err := u.QueryResource(resourceID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resource detail from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Resource = resource
+		data.Histories = resourcesHistory
+		data.IsDeleted = len(resourcesHistory) > 0 && resourcesHistory[0].Deleted
+		if resource != nil {
+			for _, org := range resource.EndorsingOrgs {
+				data.Endorsing[org] = true
+			}
+			for _, companionID := range resource.Companions {
+				data.Bundled[companionID] = true
+			}
+			// Every document attached is checked against the hash recorded in the ledger
+			for _, attachment := range resource.Attachments {
+				if _, checked := data.Documents[attachment.Hash]; !checked {
+					data.Documents[attachment.Hash] = c.Attachments.check(attachment.Hash)
+				}
+			}
+		}
+
+		data.Locations, err = u.QueryLocations()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve locations from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+
+		// The resources of the same organization that may be required as companions
+		if can["set-companions"] && resource != nil && !data.IsDeleted {
+			resources, err := u.QueryResources(model.ResourcesFilterAll)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, candidate := range resources {
+				if candidate.ID != resource.ID && candidate.Owner == resource.Owner && len(candidate.Companions) == 0 {
+					data.Resources = append(data.Resources, candidate)
+				}
+			}
+		}
+
 		renderTemplate(w, r, "resource.gohtml", data)
 	})
 }
+
+// formCost retrieve a cost in credits from the form, an empty value is a cost of zero
+func formCost(r *http.Request, name string) (int64, error) {
+	value := formInput(r, name)
+	if value == "" {
+		return 0, nil
+	}
+	cost, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || cost < 0 {
+		return 0, fmt.Errorf("the cost '%s' is not a positive number of credits", value)
+	}
+	return cost, nil
+}
@@ -19,43 +19,71 @@ import (
 	"github.com/chainHero/resource-manager/app/fabric"
 	"github.com/chainHero/resource-manager/chaincode/model"
 	"net/http"
+	"time"
 )
 
+// snapshotTimeLayout layout of the time sent by the date picker of the resources page
+const snapshotTimeLayout = "2006-01-02T15:04"
+
 // ResourcesHandler controller that allow to see resources
 func (c *Controller) ResourcesHandler() func(http.ResponseWriter, *http.Request) {
 	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
 
-		isAdmin := false
-		_, err := u.QueryAdmin()
-		if err == nil {
-			isAdmin = true
+		can := permissions(u)
+
+		location := r.URL.Query().Get("location")
+		resources, err := u.QueryResourcesInLocation(model.ResourcesFilterAll, location)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
 		}
 
-		resources, err := u.QueryResources(model.ResourcesFilterAll)
+		locations, err := u.QueryLocations()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to retrieve resources from the ledger: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Unable to retrieve locations from the ledger: %s", errorMessage(err)), errorStatus(err))
 			return
 		}
 
 		data := &struct {
 			Username         string
 			Resources        []model.Resource
+			Location         string
+			Locations        model.Locations
 			ResourcesDeleted model.ResourcesDeleted
-			IsAdmin          bool
+			At               string
+			Snapshot         []model.Resource
+			Error            string
+			Can              map[string]bool
 		}{
 			Username:  u.Username,
 			Resources: resources,
-			IsAdmin:   isAdmin,
+			Location:  location,
+			Locations: locations,
+			Can:       can,
 		}
 
-		if isAdmin {
+		if can["resources-deleted"] {
 			data.ResourcesDeleted, err = u.QueryResourcesDeleted()
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Unable to retrieve resources deleted from the ledger: %v", err), http.StatusInternalServerError)
+				http.Error(w, fmt.Sprintf("Unable to retrieve resources deleted from the ledger: %s", errorMessage(err)), errorStatus(err))
 				return
 			}
 		}
 
+		data.At = r.FormValue("at")
+		if data.At != "" && can["resources-at"] {
+			at, err := time.ParseInLocation(snapshotTimeLayout, data.At, time.Local)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				data.Error = "The date of the snapshot is not valid."
+			} else {
+				data.Snapshot, err = u.QueryResourcesAt(at)
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				}
+			}
+		}
+
 		renderTemplate(w, r, "resources.gohtml", data)
 	})
 }
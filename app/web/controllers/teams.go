@@ -0,0 +1,104 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/app/fabric"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"net/http"
+)
+
+// TeamsHandler controller that allow to see the teams and to manage them
+func (c *Controller) TeamsHandler() func(http.ResponseWriter, *http.Request) {
+	return c.basicAuth(func(w http.ResponseWriter, r *http.Request, u *fabric.User) {
+
+		can := permissions(u)
+
+		data := &struct {
+			Error         string
+			Success       bool
+			Response      bool
+			Teams         []model.Team
+			Consumers     []model.Consumer
+			ConsumerNames map[string]string
+			Priorities    []int
+			Can           map[string]bool
+			Username      string
+		}{
+			Error:         "",
+			Success:       false,
+			Response:      false,
+			Teams:         []model.Team{},
+			Consumers:     []model.Consumer{},
+			ConsumerNames: make(map[string]string),
+			Priorities:    model.Priorities,
+			Can:           can,
+			Username:      u.Username,
+		}
+		if r.FormValue(formSubmittedKey) == formSubmittedValue {
+			teamID := formInput(r, "team")
+			name := formInput(r, "name")
+			data.Error = validateInputs(w, []input{{model.KindID, teamID}, {model.KindName, name}})
+			if data.Error == "" {
+				var err error
+				switch action := r.FormValue("action"); action {
+				case "add-team":
+					_, err = u.UpdateAddTeam(teamID, name)
+				case "delete-team":
+					_, err = u.UpdateDeleteTeam(teamID)
+				case "add-team-member":
+					_, err = u.UpdateAddTeamMember(teamID, r.FormValue("consumer"))
+				case "remove-team-member":
+					_, err = u.UpdateRemoveTeamMember(teamID, r.FormValue("consumer"))
+				case "set-priority":
+					var priority int
+					priority, err = model.ParsePriority(r.FormValue("priority"))
+					if err == nil {
+						_, err = u.UpdateSetPriority(r.FormValue("consumer"), priority)
+					}
+				default:
+					err = fmt.Errorf("unknown team action '%s'", action)
+				}
+				if err != nil {
+					data.Error = transactionFailed(w, err)
+				} else {
+					data.Success = true
+				}
+			}
+			data.Response = true
+		}
+
+		teams, err := u.QueryTeams()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to retrieve teams from the ledger: %s", errorMessage(err)), errorStatus(err))
+			return
+		}
+		data.Teams = teams
+
+		if can["consumers"] {
+			data.Consumers, err = u.QueryConsumers()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to retrieve consumers from the ledger: %s", errorMessage(err)), errorStatus(err))
+				return
+			}
+			for _, consumer := range data.Consumers {
+				data.ConsumerNames[consumer.ID] = consumer.Name
+			}
+		}
+
+		renderTemplate(w, r, "teams.gohtml", data)
+	})
+}
@@ -28,10 +28,23 @@ func Serve(app *controllers.Controller) error {
 	http.HandleFunc("/home", app.HomeHandler())
 	http.HandleFunc("/resources", app.ResourcesHandler())
 	http.HandleFunc("/resource", app.ResourceHandler())
+	http.HandleFunc("/attachment", app.AttachmentHandler())
 	http.HandleFunc("/add-resource", app.AddResourceHandler())
+	http.HandleFunc("/edit-resource", app.EditResourceHandler())
 	http.HandleFunc("/delete-resource", app.DeleteResourceHandler())
 	http.HandleFunc("/acquire-resource", app.AcquireResourceHandler())
 	http.HandleFunc("/release-resource", app.ReleaseResourceHandler())
+	http.HandleFunc("/renew-resource", app.RenewResourceHandler())
+	http.HandleFunc("/check-in", app.CheckInHandler())
+	http.HandleFunc("/teams", app.TeamsHandler())
+	http.HandleFunc("/locations", app.LocationsHandler())
+	http.HandleFunc("/delegations", app.DelegationsHandler())
+	http.HandleFunc("/reservations", app.ReservationsHandler())
+	http.HandleFunc("/missions", app.MissionsHandler())
+	http.HandleFunc("/mission", app.MissionHandler())
+	http.HandleFunc("/credits", app.CreditsHandler())
+	http.HandleFunc("/audit", app.AuditHandler())
+	http.HandleFunc("/receipt", app.ReceiptHandler())
 	http.HandleFunc("/logout", app.LogoutHandler)
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,87 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"strconv"
+)
+
+func (t *ResourceManagerChaincode) attach(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# attach document to resource")
+
+	resourceID := args[0]
+
+	size, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil || size < 0 || size > model.AttachmentMaxSize {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The size '%s' is not a document size between 0 and %d bytes", args[3], model.AttachmentMaxSize), err)
+	}
+
+	var resource model.Resource
+	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only actors of the owner organization are allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 5)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	attacherID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	resource.Attachments = append(resource.Attachments, model.Attachment{
+		Hash:        args[1],
+		Name:        args[2],
+		Size:        size,
+		MimeType:    args[4],
+		Attacher:    attacherID,
+		Time:        txTime,
+		Transaction: stub.GetTxID(),
+	})
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Document attached:\n  ID -> %s\n  Name -> %s\n  Hash -> %s\n  Size -> %d\n", resourceID, args[2], args[1], size)
+
+	return shim.Success(resourceAsByte)
+}
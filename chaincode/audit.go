@@ -0,0 +1,132 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"time"
+)
+
+// auditTimeEnd upper bound of the time range of the audit log when no end is given
+var auditTimeEnd = time.Date(9999, time.December, 31, 23, 59, 59, 999999999, time.UTC)
+
+// appendAuditEntry record in the audit log an update made by the request owner
+// The key of an entry is made of the transaction time and ID, so the entries are ordered by time and never
+// overwritten by another transaction.
+func appendAuditEntry(stub shim.ChaincodeStubInterface, actorType string, action string, resourceID string) error {
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return fmt.Errorf("unable to identify the ID of the request owner: %v", err)
+	}
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	entry := model.AuditEntry{
+		Time:        txTime,
+		Actor:       actorID,
+		ActorType:   actorType,
+		Action:      action,
+		Resource:    resourceID,
+		Transaction: stub.GetTxID(),
+	}
+	key, err := stub.CreateCompositeKey(model.ObjectTypeAuditEntry, []string{txTime.Format(model.AuditTimeLayout), entry.Transaction})
+	if err != nil {
+		return fmt.Errorf("unable to create the audit entry key for the ledger: %v", err)
+	}
+	entryAsByte, err := objectToByte(entry)
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, entryAsByte)
+	if err != nil {
+		return fmt.Errorf("unable to put the audit entry in the ledger: %v", err)
+	}
+	return nil
+}
+
+// auditRangeKey build the key bounding a time range of the audit log
+func auditRangeKey(stub shim.ChaincodeStubInterface, at time.Time) (string, error) {
+	return stub.CreateCompositeKey(model.ObjectTypeAuditEntry, []string{at.UTC().Format(model.AuditTimeLayout)})
+}
+
+func (t *ResourceManagerChaincode) auditLog(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# audit log")
+
+	actorID, action := args[0], args[1]
+	from, to := time.Time{}, auditTimeEnd
+	var err error
+	if args[2] != "" {
+		from, err = time.Parse(time.RFC3339, args[2])
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The start of the time range is not a valid RFC 3339 time", err)
+		}
+	}
+	if args[3] != "" {
+		to, err = time.Parse(time.RFC3339, args[3])
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The end of the time range is not a valid RFC 3339 time", err)
+		}
+	}
+	if to.Before(from) {
+		return errorResponse(model.ErrorInvalidArgument, "The end of the time range is before its start", nil)
+	}
+
+	// The end key is exclusive, the entries made at the exact end of the range are included
+	startKey, err := auditRangeKey(stub, from)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the audit log key for the ledger", err)
+	}
+	endKey, err := auditRangeKey(stub, to.Add(time.Nanosecond))
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the audit log key for the ledger", err)
+	}
+	iterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the audit log in the ledger", err)
+	}
+	defer iterator.Close()
+
+	entries := make([]model.AuditEntry, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve an audit entry in the ledger", errIt)
+		}
+		var entry model.AuditEntry
+		err = byteToObject(keyValueState.Value, &entry)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert an audit entry", err)
+		}
+		if (actorID != "" && entry.Actor != actorID) || (action != "" && entry.Action != action) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	entriesAsByte, err := objectToByte(entries)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the audit log to byte", err)
+	}
+
+	fmt.Printf("Audit entries: %d\n", len(entries))
+
+	return shim.Success(entriesAsByte)
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"strings"
+)
+
+func (t *ResourceManagerChaincode) setCompanions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# set companions of resource")
+
+	resourceID := args[0]
+
+	// The companions are given as a comma separated list of resource IDs, an empty list remove the bundle
+	var companionIDs []string
+	known := make(map[string]bool)
+	for _, companionID := range strings.Split(args[1], ",") {
+		if companionID = strings.TrimSpace(companionID); companionID != "" && !known[companionID] {
+			known[companionID] = true
+			companionIDs = append(companionIDs, companionID)
+		}
+	}
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 2)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	// The bundle of a resource acquired is kept until it is released, so that its companions are released with it
+	if !resource.Available {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is acquired, its companions can't be changed", resourceID), nil)
+	}
+
+	// A bundle is one level deep: a resource required by another one can't require companions itself
+	if len(companionIDs) > 0 {
+		primaryID, err := getBundlePrimary(stub, resourceID)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve the bundles in the ledger", err)
+		}
+		if primaryID != "" {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The resource ID '%s' is a companion of '%s', it can't require companions", resourceID, primaryID), nil)
+		}
+	}
+	for _, companionID := range companionIDs {
+		if companionID == resourceID {
+			return errorResponse(model.ErrorInvalidArgument, "A resource can't be its own companion", nil)
+		}
+		err = model.Validate(model.KindID, companionID)
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The companion ID is invalid", err)
+		}
+		var companion model.Resource
+		err = getFromLedger(stub, model.ObjectTypeResource, companionID, &companion)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the companion resource '%s' in the ledger", companionID), err)
+		}
+		if companion.Owner != resource.Owner {
+			return errorResponse(model.ErrorForbidden, fmt.Sprintf("The companion resource '%s' is not owned by the organization of the resource", companionID), nil)
+		}
+		if len(companion.Companions) > 0 {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The resource ID '%s' require companions, it can't be a companion", companionID), nil)
+		}
+	}
+
+	resource.Companions = companionIDs
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource companions set:\n  ID -> %s\n  Companions -> %v\n", resourceID, companionIDs)
+
+	return shim.Success(resourceAsByte)
+}
+
+// getBundlePrimary retrieve the ID of a resource requiring the given resource as companion, empty if there is none
+func getBundlePrimary(stub shim.ChaincodeStubInterface, resourceID string) (string, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve the list of resource in the ledger: %v", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return "", fmt.Errorf("unable to retrieve a resource in the ledger: %v", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return "", err
+		}
+		for _, companionID := range resource.Companions {
+			if companionID == resourceID {
+				return resource.ID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// getBundle retrieve the companions acquired with the given resource
+// A companion acquired can't be deleted, so every companion of the bundle is expected in the ledger.
+func getBundle(stub shim.ChaincodeStubInterface, resource *model.Resource) ([]model.Resource, error) {
+	var companions []model.Resource
+	for _, companionID := range resource.Companions {
+		var companion model.Resource
+		err := getFromLedger(stub, model.ObjectTypeResource, companionID, &companion)
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve the companion resource '%s': %v", companionID, err)
+		}
+		if companion.Bundle == resource.ID && !companion.Available {
+			companions = append(companions, companion)
+		}
+	}
+	return companions, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"sort"
+)
+
+func (t *ResourceManagerChaincode) checkIn(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# check in resource")
+
+	resourceID := args[0]
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	if resource.Available {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is not acquired", resourceID), nil)
+	}
+
+	if resource.Bundle != "" {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' is part of the bundle of '%s', check in this resource instead", resourceID, resource.Bundle), nil)
+	}
+
+	consumerID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+	if consumerID != resource.Consumer && !isTeamMember(stub, resource.Team, consumerID) {
+		return errorResponse(model.ErrorForbidden, "Unable to check in a resource that you or your team don't hold", nil)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	// A check-in doesn't change the holding, the revision is kept so that the forms opened remain valid
+	resource.CheckedInAt = &txTime
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource checked in:\n  ID -> %s\n  Consumer ID -> %s\n  Time -> %s\n", resourceID, consumerID, txTime)
+
+	return shim.Success(resourceAsByte)
+}
+
+func (t *ResourceManagerChaincode) staleHoldings(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# stale holdings")
+
+	window, err := model.ParseStaleWindow(args[0])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The window of the stale holdings is invalid", err)
+	}
+
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
+	}
+	defer iterator.Close()
+
+	// The admins only follow the resources of their organization
+	resources := make([]model.Resource, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
+		}
+		if resource.Owner == actorOrg && resource.IsStale(txTime, window) {
+			resources = append(resources, resource)
+		}
+	}
+
+	// The holdings forgotten for the longest time first
+	sort.SliceStable(resources, func(i, j int) bool {
+		first, second := resources[i].LastCheckIn(), resources[j].LastCheckIn()
+		return first == nil && second != nil || first != nil && second != nil && first.Before(*second)
+	})
+
+	resourcesAsByte, err := objectToByte(resources)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource list to byte", err)
+	}
+
+	return shim.Success(resourcesAsByte)
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"strconv"
+	"time"
+)
+
+func (t *ResourceManagerChaincode) balance(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# balance")
+
+	consumerID, err := creditConsumer(stub, args[0])
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to retrieve the balance of the consumer", err)
+	}
+
+	account, err := getAccount(stub, consumerID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the account in the ledger", err)
+	}
+
+	accountAsByte, err := objectToByte(account)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the account to byte", err)
+	}
+
+	return shim.Success(accountAsByte)
+}
+
+func (t *ResourceManagerChaincode) creditMovements(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# credit movements")
+
+	consumerID, err := creditConsumer(stub, args[0])
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to retrieve the credit movements of the consumer", err)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeCreditMovement, []string{consumerID})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the credit movements in the ledger", err)
+	}
+	defer iterator.Close()
+
+	movements := make([]model.CreditMovement, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a credit movement in the ledger", errIt)
+		}
+		var movement model.CreditMovement
+		err = byteToObject(keyValueState.Value, &movement)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a credit movement", err)
+		}
+		movements = append(movements, movement)
+	}
+
+	movementsAsByte, err := objectToByte(movements)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the credit movements to byte", err)
+	}
+
+	return shim.Success(movementsAsByte)
+}
+
+func (t *ResourceManagerChaincode) allocateCredits(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# allocate credits")
+
+	consumerID := args[0]
+
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || amount == 0 {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The amount '%s' is not a number of credits", args[1]), err)
+	}
+
+	var consumer model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, consumerID, &consumer)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the consumer in the ledger", err)
+	}
+
+	// A negative amount withdraw credits, the balance can't become negative this way
+	account, err := getAccount(stub, consumerID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the account in the ledger", err)
+	}
+	if amount < 0 && account.Balance+amount < 0 {
+		return errorResponse(model.ErrorNoCredit, fmt.Sprintf("The consumer has only %d credits left", account.Balance), nil)
+	}
+
+	account, err = moveCredits(stub, consumerID, amount, model.CreditAllocation, "")
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the account in the ledger", err)
+	}
+
+	accountAsByte, err := objectToByte(account)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the account to byte", err)
+	}
+
+	fmt.Printf("Credits allocated:\n  Consumer ID -> %s\n  Amount -> %d\n  Balance -> %d\n", consumerID, amount, account.Balance)
+
+	return shim.Success(accountAsByte)
+}
+
+func (t *ResourceManagerChaincode) setCost(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# set cost of resource")
+
+	resourceID := args[0]
+
+	// An empty cost is free
+	var costs [2]int64
+	for i, arg := range args[1:3] {
+		if arg == "" {
+			continue
+		}
+		cost, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || cost < 0 {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The cost '%s' is not a positive number of credits", arg), err)
+		}
+		costs[i] = cost
+	}
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 3)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	// The costs of a resource acquired apply from its next acquisition, the current holding is charged at the old rate
+	if !resource.Available {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is acquired, its cost can't be changed", resourceID), nil)
+	}
+
+	resource.CostPerUse = costs[0]
+	resource.CostPerHour = costs[1]
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource cost set:\n  ID -> %s\n  Per use -> %d\n  Per hour -> %d\n", resourceID, resource.CostPerUse, resource.CostPerHour)
+
+	return shim.Success(resourceAsByte)
+}
+
+// creditConsumer retrieve the consumer whose credits are asked, a consumer only has access to its own credits
+func creditConsumer(stub shim.ChaincodeStubInterface, consumerID string) (string, error) {
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return "", fmt.Errorf("unable to identify the type of the request owner: %v", err)
+	}
+	if !found {
+		return "", fmt.Errorf("the type of the request owner is not present")
+	}
+	if actorType != model.ActorConsumer {
+		if consumerID == "" {
+			return "", fmt.Errorf("the consumer is required")
+		}
+		return consumerID, nil
+	}
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return "", fmt.Errorf("unable to identify the ID of the request owner: %v", err)
+	}
+	if consumerID != "" && consumerID != actorID {
+		return "", fmt.Errorf("a consumer only has access to its own credits")
+	}
+	return actorID, nil
+}
+
+// getAccount retrieve the credit account of a consumer, with a balance of zero if it never had credits
+func getAccount(stub shim.ChaincodeStubInterface, consumerID string) (model.Account, error) {
+	key, err := stub.CreateCompositeKey(model.ObjectTypeAccount, []string{consumerID})
+	if err != nil {
+		return model.Account{}, fmt.Errorf("unable to create the account key for the ledger: %v", err)
+	}
+	accountAsByte, err := stub.GetState(key)
+	if err != nil {
+		return model.Account{}, fmt.Errorf("unable to retrieve the account in the ledger: %v", err)
+	}
+	account := model.Account{Consumer: consumerID}
+	if accountAsByte != nil {
+		err = byteToObject(accountAsByte, &account)
+		if err != nil {
+			return model.Account{}, err
+		}
+	}
+	return account, nil
+}
+
+// moveCredits add an amount (negative for a debit) to the balance of a consumer and record the movement
+// The balance is not checked, the caller reject the operations that can't make it negative.
+func moveCredits(stub shim.ChaincodeStubInterface, consumerID string, amount int64, reason string, resourceID string) (model.Account, error) {
+	account, err := getAccount(stub, consumerID)
+	if err != nil {
+		return model.Account{}, err
+	}
+	if amount == 0 {
+		return account, nil
+	}
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	account.Balance += amount
+	err = updateInLedger(stub, model.ObjectTypeAccount, consumerID, account)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	movement := model.CreditMovement{
+		Consumer:    consumerID,
+		Amount:      amount,
+		Balance:     account.Balance,
+		Reason:      reason,
+		Resource:    resourceID,
+		Transaction: stub.GetTxID(),
+		Time:        txTime,
+	}
+	key, err := stub.CreateCompositeKey(model.ObjectTypeCreditMovement, []string{consumerID, txTime.Format(model.AuditTimeLayout), movement.Transaction})
+	if err != nil {
+		return model.Account{}, fmt.Errorf("unable to create the credit movement key for the ledger: %v", err)
+	}
+	movementAsByte, err := objectToByte(movement)
+	if err != nil {
+		return model.Account{}, err
+	}
+	err = stub.PutState(key, movementAsByte)
+	if err != nil {
+		return model.Account{}, fmt.Errorf("unable to put the credit movement in the ledger: %v", err)
+	}
+	return account, nil
+}
+
+// usageCost cost of holding the given resources from their acquisition (or last charge) until the given time
+func usageCost(resources []model.Resource, until time.Time) int64 {
+	var cost int64
+	for _, resource := range resources {
+		if resource.AcquiredAt != nil {
+			cost += resource.CostPerHour * model.UsageHours(*resource.AcquiredAt, until)
+		}
+	}
+	return cost
+}
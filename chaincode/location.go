@@ -0,0 +1,206 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+func (t *ResourceManagerChaincode) locations(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# locations")
+
+	locations, err := getLocations(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the locations in the ledger", err)
+	}
+
+	locationsAsByte, err := objectToByte(locations)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the location list to byte", err)
+	}
+
+	return shim.Success(locationsAsByte)
+}
+
+func (t *ResourceManagerChaincode) addLocation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# add location")
+
+	location := model.Location{ID: args[0], Name: args[1], Kind: args[2], Parent: args[3]}
+
+	var existing model.Location
+	if getFromLedger(stub, model.ObjectTypeLocation, location.ID, &existing) == nil {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The location ID '%s' already exists", location.ID), nil)
+	}
+
+	// The location is added under a location of the kind just above it in the tree
+	parentKind, err := model.ParentKind(location.Kind)
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The kind of the location is invalid", err)
+	}
+	if parentKind == "" && location.Parent != "" {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("A %s can't be located in another location", location.Kind), nil)
+	}
+	if parentKind != "" {
+		var parent model.Location
+		err = getFromLedger(stub, model.ObjectTypeLocation, location.Parent, &parent)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the parent location '%s' in the ledger", location.Parent), err)
+		}
+		if parent.Kind != parentKind {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("A %s must be located in a %s", location.Kind, parentKind), nil)
+		}
+	}
+
+	err = updateInLedger(stub, model.ObjectTypeLocation, location.ID, location)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the location in the ledger", err)
+	}
+
+	locationAsByte, err := objectToByte(location)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the location to byte", err)
+	}
+
+	fmt.Printf("Location created:\n  ID -> %s\n  Name -> %s\n  Kind -> %s\n  Parent -> %s\n", location.ID, location.Name, location.Kind, location.Parent)
+
+	return shim.Success(locationAsByte)
+}
+
+func (t *ResourceManagerChaincode) deleteLocation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# delete location")
+
+	locationID := args[0]
+
+	locations, err := getLocations(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the locations in the ledger", err)
+	}
+	if _, found := locations.Find(locationID); !found {
+		return errorResponse(model.ErrorNotFound, fmt.Sprintf("The location ID '%s' doesn't exist", locationID), nil)
+	}
+
+	// Only an empty location can be deleted, so that no resource refers to a location that doesn't exist
+	for _, location := range locations {
+		if location.Parent == locationID {
+			return errorResponse(model.ErrorConflict, fmt.Sprintf("The location can't be deleted because it contains the location '%s'", location.ID), nil)
+		}
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
+		}
+		if resource.Location == locationID {
+			return errorResponse(model.ErrorConflict, fmt.Sprintf("The location can't be deleted because it contains the resource '%s'", resource.ID), nil)
+		}
+	}
+
+	err = deleteFromLedger(stub, model.ObjectTypeLocation, locationID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to delete the location in the ledger", err)
+	}
+
+	fmt.Printf("Location deleted:\n  ID -> %s\n", locationID)
+
+	return shim.Success(nil)
+}
+
+func (t *ResourceManagerChaincode) move(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# move resource")
+
+	resourceID, locationID := args[0], args[1]
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 2)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	// An empty location remove the resource from the location tree
+	if locationID != "" {
+		var location model.Location
+		err = getFromLedger(stub, model.ObjectTypeLocation, locationID, &location)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the location '%s' in the ledger", locationID), err)
+		}
+	}
+
+	resource.Location = locationID
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource moved:\n  ID -> %s\n  Location -> %s\n", resourceID, locationID)
+
+	return shim.Success(resourceAsByte)
+}
+
+// getLocations retrieve every location of the location tree
+func getLocations(stub shim.ChaincodeStubInterface) (model.Locations, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeLocation, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the locations in the ledger: %v", err)
+	}
+	defer iterator.Close()
+	locations := make(model.Locations, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return nil, fmt.Errorf("unable to retrieve a location in the ledger: %v", errIt)
+		}
+		var location model.Location
+		err = byteToObject(keyValueState.Value, &location)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
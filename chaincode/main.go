@@ -26,8 +26,10 @@ type ResourceManagerChaincode struct {
 }
 
 // Init of the chaincode
-// This function is called only one when the chaincode is instantiated.
-// So the goal is to prepare the ledger to handle future requests.
+// This function is called when the chaincode is instantiated and every time it is upgraded.
+// So the goal is to prepare the ledger to handle future requests, without losing the data already recorded:
+// the migrations not yet applied to the ledger are run (they seed the ledger on the first instantiation).
+// If they don't complete in a single batch, an admin resume them with the migrate action.
 func (t *ResourceManagerChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("########### ResourceManagerChaincode Init ###########")
 
@@ -36,52 +38,70 @@ func (t *ResourceManagerChaincode) Init(stub shim.ChaincodeStubInterface) pb.Res
 
 	// Check if the request is the init function
 	if function != "init" {
-		return shim.Error("Unknown function call")
+		return errorResponse(model.ErrorInvalidArgument, "Unknown function call", nil)
 	}
 
-	var deletedResources []model.Resource
-	err := updateInLedger(stub, model.ObjectTypeResourcesDeleted, "", deletedResources)
+	schema, err := runMigrations(stub, defaultMigrationBatchSize, "", true)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to initialize the deleted resources map: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to migrate the ledger", err)
 	}
 
+	fmt.Printf("Schema:\n  Version -> %d\n  Latest -> %d\n", schema.Version, schema.Latest)
+
 	// Return a successful message
 	return shim.Success(nil)
 }
 
 // Invoke on the chaincode
-// All future requests named invoke will arrive here.
+// All future requests will arrive here.
 // It is the main entry point for the chaincode after the init proceed.
+// A request is either a versioned JSON envelope given to the request function (see model.Request), or
+// the positional form "invoke" -> "query" or "update" -> action -> arguments in the order of the action definition.
 func (t *ResourceManagerChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("########### ResourceManagerChaincode Invoke ###########")
 
 	// Get the function and arguments from the request
 	function, args := stub.GetFunctionAndParameters()
 
+	if function == model.RequestFunction {
+		if len(args) != 1 {
+			return errorResponse(model.ErrorInvalidArgument, "The request envelope must be the single argument.", nil)
+		}
+		var request model.Request
+		err := byteToObject([]byte(args[0]), &request)
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "Unable to read the request envelope", err)
+		}
+		return t.route(stub, request)
+	}
+
 	// Check whether it is an invoke request
 	if function != "invoke" {
-		return shim.Error("Unknown function call")
+		return errorResponse(model.ErrorInvalidArgument, "Unknown function call", nil)
 	}
 
 	// Check whether the number of arguments is sufficient
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	if len(args) < 2 {
+		return errorResponse(model.ErrorInvalidArgument, "The number of arguments is insufficient.", nil)
 	}
 
-	// In order to manage multiple type of request, we will check the first argument.
-
-	// Here we have one possible argument: query (every query request will read in the ledger without modification)
-	if args[0] == "query" {
-		return t.query(stub, args[1:])
+	// The first argument tell whether the request read in the ledger without modification (query) or not (update)
+	if args[0] != "query" && args[0] != "update" {
+		return errorResponse(model.ErrorInvalidArgument, "Unknown action, check the first argument", nil)
 	}
-
-	// The update argument will manage all update in the ledger
-	if args[0] == "update" {
-		return t.update(stub, args[1:])
+	action, found := model.FindAction(args[1])
+	if !found {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("Unknown action '%s'", args[1]), nil)
+	}
+	if action.Write != (args[0] == "update") {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The action '%s' doesn't match the kind of request '%s'", action.Name, args[0]), nil)
+	}
+	namedArgs, err := action.Named(args[2:])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The arguments of the request are invalid", err)
 	}
 
-	// If the arguments given don’t match any function, we return an error
-	return shim.Error("Unknown action, check the first argument")
+	return t.route(stub, model.NewRequest(action.Name, namedArgs))
 }
 
 func main() {
@@ -0,0 +1,302 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// defaultMigrationBatchSize number of objects migrated in a single transaction if no other size is asked
+const defaultMigrationBatchSize = 100
+
+// migration that convert the data stored in the ledger to the shape expected by a new version of the chaincode
+// A migration process at most the given number of objects starting after the bookmark given (empty at first call),
+// it returns the number of objects processed and the new bookmark, an empty bookmark meaning that it is complete.
+// A private migration use the private data, not available at the instantiation, so it is only run by the migrate action.
+// It is run for each organization in turn (see runMigrations), so that only the peers of the organization, members of
+// its private data collections, endorse it, then without organization to complete it.
+type migration struct {
+	description string
+	migrate     func(stub shim.ChaincodeStubInterface, bookmark string, batchSize int) (int, string, error)
+	migrateOrg  func(stub shim.ChaincodeStubInterface, org string, bookmark string, batchSize int) (int, string, error)
+}
+
+// migrations list of the migrations in the order they must be applied, never remove or reorder an entry
+// The schema version of the ledger is the number of migrations applied, so a new migration is always appended.
+var migrations = []migration{
+	{
+		description: "initialize the list of deleted resources",
+		migrate:     migrateResourcesDeleted,
+	},
+	{
+		description: "set the first revision of the resources recorded before revisions",
+		migrate:     migrateResourcesRevision,
+	},
+	{
+		description: "count the resources in the statistics",
+		migrate:     migrateStats,
+	},
+	{
+		description: "require the endorsement of the owner organization for the resources without policy",
+		migrate:     migrateResourcesEndorsement,
+	},
+	{
+		description: "move the missions of the resources to the private data collection of their owner organization",
+		migrateOrg:  migrateResourcesMission,
+	},
+}
+
+// getSchema retrieve the schema of the ledger, a ledger without schema is at the version 0
+func getSchema(stub shim.ChaincodeStubInterface) (model.Schema, error) {
+	schema := model.Schema{}
+	key, err := stub.CreateCompositeKey(model.ObjectTypeSchema, []string{""})
+	if err != nil {
+		return schema, fmt.Errorf("unable to create the schema key for the ledger: %v", err)
+	}
+	schemaAsByte, err := stub.GetState(key)
+	if err != nil {
+		return schema, fmt.Errorf("unable to retrieve the schema in the ledger: %v", err)
+	}
+	if schemaAsByte != nil {
+		err = byteToObject(schemaAsByte, &schema)
+		if err != nil {
+			return schema, fmt.Errorf("unable to convert the schema: %v", err)
+		}
+	}
+	schema.Latest = len(migrations)
+	schema.Private = schema.Version < len(migrations) && migrations[schema.Version].migrateOrg != nil
+	return schema, nil
+}
+
+// runMigrations apply the migrations not yet applied to the ledger, processing at most the given number of objects
+// The schema is updated in the ledger so that the next call resume where this one stopped.
+// At the instantiation, the migrations stop at the first private one, the migrate action resume them. With an
+// organization (MSP ID), only the private migration in progress is applied to the data of this organization, the
+// migration is then completed without organization, once every organization is migrated.
+func runMigrations(stub shim.ChaincodeStubInterface, batchSize int, org string, fromInit bool) (model.Schema, error) {
+	schema, err := getSchema(stub)
+	if err != nil {
+		return schema, err
+	}
+	if batchSize <= 0 {
+		batchSize = defaultMigrationBatchSize
+	}
+
+	for schema.Version < len(migrations) && batchSize > 0 {
+		m := migrations[schema.Version]
+		if m.migrateOrg != nil && fromInit {
+			fmt.Printf("Migration %d (%s) postponed to the migrate action\n", schema.Version+1, m.description)
+			break
+		}
+		if m.migrateOrg == nil && org != "" {
+			return schema, fmt.Errorf("the migration %d (%s) is not run by organization", schema.Version+1, m.description)
+		}
+		if m.migrateOrg != nil && org != "" {
+			fmt.Printf("Migration %d (%s) of the organization '%s' from bookmark '%s'\n", schema.Version+1, m.description, org, schema.Orgs[org])
+			_, bookmark, err := m.migrateOrg(stub, org, schema.Orgs[org], batchSize)
+			if err != nil {
+				return schema, fmt.Errorf("unable to apply the migration %d (%s) to the organization '%s': %v", schema.Version+1, m.description, org, err)
+			}
+			if schema.Orgs == nil {
+				schema.Orgs = make(map[string]string)
+			}
+			schema.Orgs[org] = bookmark
+			break
+		}
+
+		fmt.Printf("Migration %d (%s) from bookmark '%s'\n", schema.Version+1, m.description, schema.Bookmark)
+		var processed int
+		var bookmark string
+		if m.migrateOrg != nil {
+			processed, bookmark, err = m.migrateOrg(stub, "", schema.Bookmark, batchSize)
+		} else {
+			processed, bookmark, err = m.migrate(stub, schema.Bookmark, batchSize)
+		}
+		if err != nil {
+			return schema, fmt.Errorf("unable to apply the migration %d (%s): %v", schema.Version+1, m.description, err)
+		}
+		batchSize -= processed
+		schema.Bookmark = bookmark
+		if bookmark == "" {
+			schema.Version++
+			schema.Orgs = nil
+		}
+	}
+
+	// The latest version and whether the migration in progress is private are computed from the chaincode, so they
+	// are never stored
+	latest := schema.Latest
+	schema.Latest = 0
+	schema.Private = false
+	err = updateInLedger(stub, model.ObjectTypeSchema, "", schema)
+	if err != nil {
+		return schema, fmt.Errorf("unable to update the schema in the ledger: %v", err)
+	}
+	schema.Latest = latest
+	schema.Private = schema.Version < len(migrations) && migrations[schema.Version].migrateOrg != nil
+
+	return schema, nil
+}
+
+// migrateObjects apply the given conversion to the objects of the given type stored after the bookmark
+// The conversion returns nil if the object doesn't need to be updated.
+func migrateObjects(stub shim.ChaincodeStubInterface, objectType string, bookmark string, batchSize int, convert func(objectAsByte []byte) ([]byte, error)) (int, string, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, "", fmt.Errorf("unable to retrieve the list of %s in the ledger: %v", objectType, err)
+	}
+	defer iterator.Close()
+
+	processed := 0
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return processed, "", fmt.Errorf("unable to retrieve a %s in the ledger: %v", objectType, errIt)
+		}
+		// The keys are returned in lexical order, so the ones up to the bookmark are already migrated
+		if keyValueState.Key <= bookmark {
+			continue
+		}
+		if processed == batchSize {
+			return processed, bookmark, nil
+		}
+		objectAsByte, err := convert(keyValueState.Value)
+		if err != nil {
+			return processed, "", fmt.Errorf("unable to convert the %s '%s': %v", objectType, keyValueState.Key, err)
+		}
+		if objectAsByte != nil {
+			err = stub.PutState(keyValueState.Key, objectAsByte)
+			if err != nil {
+				return processed, "", fmt.Errorf("unable to update the %s '%s' in the ledger: %v", objectType, keyValueState.Key, err)
+			}
+		}
+		bookmark = keyValueState.Key
+		processed++
+	}
+	return processed, "", nil
+}
+
+// migrateResourcesDeleted create the empty list of deleted resources, unless it already exists
+func migrateResourcesDeleted(stub shim.ChaincodeStubInterface, bookmark string, batchSize int) (int, string, error) {
+	var resourcesDeleted model.ResourcesDeleted
+	if getFromLedger(stub, model.ObjectTypeResourcesDeleted, "", &resourcesDeleted) == nil {
+		return 0, "", nil
+	}
+	err := updateInLedger(stub, model.ObjectTypeResourcesDeleted, "", model.ResourcesDeleted{})
+	if err != nil {
+		return 0, "", fmt.Errorf("unable to initialize the list of deleted resources: %v", err)
+	}
+	return 1, "", nil
+}
+
+// migrateResourcesRevision set the revision 1 to the resources recorded without revision
+func migrateResourcesRevision(stub shim.ChaincodeStubInterface, bookmark string, batchSize int) (int, string, error) {
+	return migrateObjects(stub, model.ObjectTypeResource, bookmark, batchSize, func(resourceAsByte []byte) ([]byte, error) {
+		var resource model.Resource
+		err := byteToObject(resourceAsByte, &resource)
+		if err != nil {
+			return nil, err
+		}
+		if resource.Revision > 0 {
+			return nil, nil
+		}
+		resource.Revision = 1
+		return objectToByte(resource)
+	})
+}
+
+// migrateResourcesEndorsement set the key-level endorsement policy of the owner organization on the resources
+// recorded before it was the default
+func migrateResourcesEndorsement(stub shim.ChaincodeStubInterface, bookmark string, batchSize int) (int, string, error) {
+	return migrateObjects(stub, model.ObjectTypeResource, bookmark, batchSize, func(resourceAsByte []byte) ([]byte, error) {
+		var resource model.Resource
+		err := byteToObject(resourceAsByte, &resource)
+		if err != nil {
+			return nil, err
+		}
+		if len(resource.EndorsingOrgs) > 0 || resource.Owner == "" {
+			return nil, nil
+		}
+		resource.EndorsingOrgs = []string{resource.Owner}
+		err = updateEndorsementInLedger(stub, model.ObjectTypeResource, resource.ID, resource.EndorsingOrgs)
+		if err != nil {
+			return nil, err
+		}
+		return objectToByte(resource)
+	})
+}
+
+// migrateResourcesMission move the missions of the resources acquired owned by the given organization (MSP ID) to its
+// private data collection: the mission stored in the world state before the missions were private, or in the
+// collection shared by every organization, is stored salted and cleared from the world state.
+// Only the world state and the collection shared are read, so every peer endorsing it compute the same writes. The salt
+// of each resource is derived from the seed given through the transient map, for the same reason. Without
+// organization, the resources without owner are migrated and the migration is rejected while the mission of a
+// resource of an organization is not migrated.
+func migrateResourcesMission(stub shim.ChaincodeStubInterface, org string, bookmark string, batchSize int) (int, string, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return 0, "", fmt.Errorf("unable to retrieve the transient map: %v", err)
+	}
+	seed, err := missionSalt(transient)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return migrateObjects(stub, model.ObjectTypeResource, bookmark, batchSize, func(resourceAsByte []byte) ([]byte, error) {
+		var resource model.Resource
+		err := byteToObject(resourceAsByte, &resource)
+		if err != nil {
+			return nil, err
+		}
+		resourceMission := model.ResourceMission{ResourceID: resource.ID, Mission: resource.Mission, Consumer: resource.Consumer}
+		shared := getPrivateFromLedger(stub, model.CollectionMissions, model.ObjectTypeResourceMission, resource.ID, &resourceMission) == nil
+		migrated := resource.Mission == "" && (!shared || resource.Owner == "")
+		if migrated {
+			return nil, nil
+		}
+		if resource.Owner != org {
+			if org == "" {
+				return nil, fmt.Errorf("the mission of the resource '%s' of the organization '%s' is not migrated yet", resource.ID, resource.Owner)
+			}
+			return nil, nil
+		}
+		if resource.Available {
+			resource.Mission = ""
+			err = deleteResourceMission(stub, &resource)
+			if err != nil {
+				return nil, err
+			}
+			return objectToByte(resource)
+		}
+
+		if len(resourceMission.Salt) == 0 {
+			mac := hmac.New(sha256.New, seed)
+			mac.Write([]byte(resource.ID))
+			resourceMission.Salt = mac.Sum(nil)
+		}
+		err = putResourceMission(stub, &resource, resourceMission)
+		if err != nil {
+			return nil, err
+		}
+		resource.Mission = ""
+		resource.MissionHash = model.MissionHash(resourceMission.Mission, resourceMission.Salt)
+		return objectToByte(resource)
+	})
+}
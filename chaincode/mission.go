@@ -0,0 +1,324 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"time"
+)
+
+func (t *ResourceManagerChaincode) missions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# missions list")
+
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeMission, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of missions in the ledger", err)
+	}
+	defer iterator.Close()
+
+	// Only the missions of the organization of the request owner are returned
+	missions := make([]model.Mission, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a mission in the ledger", errIt)
+		}
+		var mission model.Mission
+		err = byteToObject(keyValueState.Value, &mission)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a mission", err)
+		}
+		if mission.Org == actorOrg {
+			missions = append(missions, mission)
+		}
+	}
+
+	missionsAsByte, err := objectToByte(missions)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the missions list to byte", err)
+	}
+
+	return shim.Success(missionsAsByte)
+}
+
+func (t *ResourceManagerChaincode) mission(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# mission")
+
+	missionID := args[0]
+
+	var detail model.MissionDetail
+	err := getFromLedger(stub, model.ObjectTypeMission, missionID, &detail.Mission)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the mission in the ledger", err)
+	}
+
+	err = assertOrg(stub, detail.Mission.Org)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only the actors of the mission organization are allowed for the kind of request", err)
+	}
+
+	detail.Acquired = make([]model.Resource, 0)
+	detail.Timeline = []model.MissionEvent{{Time: detail.Mission.Created, Event: model.MissionEventCreated, Consumer: detail.Mission.Owner}}
+	for _, resourceID := range detail.Mission.Resources {
+		var resource model.Resource
+		if getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource) == nil && isAcquiredFor(&resource, missionID) {
+			detail.Acquired = append(detail.Acquired, resource)
+		}
+
+		// The resource joins the mission when acquired for it and leaves it when released or preempted
+		resourceHistories, err := getResourceHistories(stub, resourceID)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to retrieve the history of the resource '%s' in the ledger", resourceID), err)
+		}
+		sort.Sort(sort.Reverse(resourceHistories))
+		var previous *model.Resource
+		for i, resourceHistory := range resourceHistories {
+			current := &resourceHistories[i].Resource
+			if resourceHistory.Deleted {
+				current = nil
+			}
+			joined := current != nil && isAcquiredFor(current, missionID)
+			left := previous != nil && isAcquiredFor(previous, missionID)
+			switch {
+			case joined && !left:
+				detail.Timeline = append(detail.Timeline, model.MissionEvent{Time: resourceHistory.Time, Event: model.MissionEventAcquired, Resource: resourceID, Consumer: current.Consumer, Transaction: resourceHistory.Transaction})
+			case left && !joined:
+				event := model.MissionEventReleased
+				if current != nil && len(current.Preempted) > 0 && current.Preempted[0].Transaction == resourceHistory.Transaction {
+					event = model.MissionEventPreempted
+				}
+				detail.Timeline = append(detail.Timeline, model.MissionEvent{Time: resourceHistory.Time, Event: event, Resource: resourceID, Consumer: previous.Consumer, Transaction: resourceHistory.Transaction})
+			}
+			previous = current
+		}
+	}
+	if detail.Mission.Closed != nil {
+		detail.Timeline = append(detail.Timeline, model.MissionEvent{Time: *detail.Mission.Closed, Event: model.MissionEventClosed})
+	}
+	sort.SliceStable(detail.Timeline, func(i, j int) bool { return detail.Timeline[i].Time.Before(detail.Timeline[j].Time) })
+
+	detailAsByte, err := objectToByte(detail)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the mission to byte", err)
+	}
+
+	return shim.Success(detailAsByte)
+}
+
+func (t *ResourceManagerChaincode) addMission(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# add mission")
+
+	missionID := args[0]
+
+	start, err := time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The start of the mission is not a valid RFC 3339 time", err)
+	}
+	var end *time.Time
+	if args[3] != "" {
+		missionEnd, err := time.Parse(time.RFC3339, args[3])
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The end of the mission is not a valid RFC 3339 time", err)
+		}
+		if !missionEnd.After(start) {
+			return errorResponse(model.ErrorInvalidArgument, "The end of the mission must be after its start", nil)
+		}
+		missionEnd = missionEnd.UTC()
+		end = &missionEnd
+	}
+
+	var existing model.Mission
+	if getFromLedger(stub, model.ObjectTypeMission, missionID, &existing) == nil {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The mission ID '%s' already exists", missionID), nil)
+	}
+
+	consumerID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	consumerOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	mission := model.Mission{
+		ID:      missionID,
+		Title:   args[1],
+		Owner:   consumerID,
+		Org:     consumerOrg,
+		Start:   start.UTC(),
+		End:     end,
+		Status:  model.MissionOpen,
+		Created: txTime,
+	}
+	err = updateInLedger(stub, model.ObjectTypeMission, missionID, mission)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to add the mission in the ledger", err)
+	}
+
+	missionAsByte, err := objectToByte(mission)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the mission to byte", err)
+	}
+
+	fmt.Printf("Mission added:\n  ID -> %s\n  Title -> %s\n  Owner -> %s\n", missionID, mission.Title, consumerID)
+
+	return shim.Success(missionAsByte)
+}
+
+func (t *ResourceManagerChaincode) closeMission(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# close mission")
+
+	missionID := args[0]
+
+	var mission model.Mission
+	err := getFromLedger(stub, model.ObjectTypeMission, missionID, &mission)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the mission in the ledger", err)
+	}
+
+	if mission.Status == model.MissionClosed {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The mission ID '%s' is already closed", missionID), nil)
+	}
+
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
+	}
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
+	}
+
+	switch actorType {
+	case model.ActorAdmin, model.ActorManager:
+		err = assertOrg(stub, mission.Org)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Only admin or manager of the mission organization is allowed for the kind of request", err)
+		}
+	case model.ActorConsumer:
+		consumerID, err := cid.GetID(stub)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+		}
+		if consumerID != mission.Owner {
+			return errorResponse(model.ErrorForbidden, "Unable to close the mission of another consumer", nil)
+		}
+	default:
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is unknown", nil)
+	}
+
+	// Every resource still acquired for the mission is released
+	released, err := releaseMission(stub, mission)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to release the resources of the mission in the ledger", err)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+	mission.Status = model.MissionClosed
+	mission.Closed = &txTime
+	err = updateInLedger(stub, model.ObjectTypeMission, missionID, mission)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the mission in the ledger", err)
+	}
+
+	missionAsByte, err := objectToByte(mission)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the mission to byte", err)
+	}
+
+	fmt.Printf("Mission closed:\n  ID -> %s\n  Resources released -> %v\n", missionID, released)
+
+	return shim.Success(missionAsByte)
+}
+
+// isAcquiredFor check whether the given resource is acquired for the given mission
+func isAcquiredFor(resource *model.Resource, missionID string) bool {
+	return !resource.Available && resource.MissionID == missionID
+}
+
+// releaseMission release every resource still acquired for a mission, the companions with the resource they follow
+// The hours held are charged once per consumer for all its resources, since a transaction doesn't read its own
+// writes: several charges of a consumer would start from the same balance and record the same movement.
+func releaseMission(stub shim.ChaincodeStubInterface, mission model.Mission) ([]string, error) {
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	var released []string
+	costs := make(map[string]int64)
+	charged := make(map[string]string)
+	for _, resourceID := range mission.Resources {
+		var resource model.Resource
+		if getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource) != nil || !isAcquiredFor(&resource, mission.ID) || resource.Bundle != "" {
+			continue
+		}
+		companions, err := getBundle(stub, &resource)
+		if err != nil {
+			return nil, err
+		}
+
+		// The movement refers to the resource charged, unless it covers several ones
+		if _, found := costs[resource.Consumer]; found {
+			charged[resource.Consumer] = ""
+		} else {
+			charged[resource.Consumer] = resourceID
+		}
+		costs[resource.Consumer] += usageCost(append([]model.Resource{resource}, companions...), txTime)
+
+		_, err = returnBundle(stub, resource, companions, "", nil, txTime)
+		if err != nil {
+			return nil, fmt.Errorf("unable to release the resource '%s': %v", resourceID, err)
+		}
+		released = append(released, resourceID)
+	}
+
+	// The consumers are charged in a stable order, so that every peer endorse the same writes
+	consumers := make([]string, 0, len(costs))
+	for consumerID := range costs {
+		consumers = append(consumers, consumerID)
+	}
+	sort.Strings(consumers)
+	for _, consumerID := range consumers {
+		_, err = moveCredits(stub, consumerID, -costs[consumerID], model.CreditUsage, charged[consumerID])
+		if err != nil {
+			return nil, fmt.Errorf("unable to charge the usage of the resources of the consumer '%s': %v", consumerID, err)
+		}
+	}
+	return released, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ledgerStub stub of a transaction over a ledger, the writes of the transaction are not read by it like on a peer
+type ledgerStub struct {
+	shim.ChaincodeStubInterface
+	state  map[string][]byte
+	writes map[string][]byte
+	txTime time.Time
+}
+
+func newLedgerStub(txTime time.Time) *ledgerStub {
+	return &ledgerStub{state: make(map[string][]byte), writes: make(map[string][]byte), txTime: txTime}
+}
+
+// put write an object in the ledger, before the transaction
+func (s *ledgerStub) put(t *testing.T, objectType string, id string, object interface{}) {
+	key, _ := s.CreateCompositeKey(objectType, []string{id})
+	objectAsByte, err := objectToByte(object)
+	if err != nil {
+		t.Fatalf("unable to convert the object '%s': %v", id, err)
+	}
+	s.state[key] = objectAsByte
+}
+
+func (s *ledgerStub) GetTxID() string { return "tx-1" }
+
+func (s *ledgerStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.txTime.Unix(), Nanos: int32(s.txTime.Nanosecond())}, nil
+}
+
+func (s *ledgerStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return "\x00" + objectType + "\x00" + strings.Join(attributes, "\x00") + "\x00", nil
+}
+
+func (s *ledgerStub) GetState(key string) ([]byte, error) { return s.state[key], nil }
+
+func (s *ledgerStub) PutState(key string, value []byte) error {
+	s.writes[key] = value
+	return nil
+}
+
+func (s *ledgerStub) DelPrivateData(collection string, key string) error { return nil }
+
+func TestReleaseMissionChargesEveryResource(t *testing.T) {
+	acquiredAt := time.Date(2018, 6, 1, 8, 0, 0, 0, time.UTC)
+	txTime := acquiredAt.Add(150 * time.Minute)
+	stub := newLedgerStub(txTime)
+
+	mission := model.Mission{ID: "mission-1", Owner: "alice", Org: "Org1MSP", Status: model.MissionOpen, Resources: []string{"resource-1", "resource-2"}}
+	stub.put(t, model.ObjectTypeMission, mission.ID, mission)
+	stub.put(t, model.ObjectTypeAccount, "alice", model.Account{Consumer: "alice", Balance: 100})
+	for id, costPerHour := range map[string]int64{"resource-1": 5, "resource-2": 3} {
+		stub.put(t, model.ObjectTypeResource, id, model.Resource{
+			ID: id, Owner: "Org1MSP", Consumer: "alice", MissionID: mission.ID, AcquiredAt: &acquiredAt, CostPerHour: costPerHour,
+		})
+	}
+
+	released, err := releaseMission(stub, mission)
+	if err != nil {
+		t.Fatalf("unable to release the mission: %v", err)
+	}
+	if len(released) != 2 {
+		t.Fatalf("the resources released are %v, expected both resources", released)
+	}
+
+	// The two resources are held 3 started hours each
+	expected := int64(100 - 3*5 - 3*3)
+	accountKey, _ := stub.CreateCompositeKey(model.ObjectTypeAccount, []string{"alice"})
+	var account model.Account
+	err = byteToObject(stub.writes[accountKey], &account)
+	if err != nil {
+		t.Fatalf("unable to read the account: %v", err)
+	}
+	if account.Balance != expected {
+		t.Fatalf("the balance is %d, expected %d", account.Balance, expected)
+	}
+
+	movementPrefix, _ := stub.CreateCompositeKey(model.ObjectTypeCreditMovement, []string{"alice"})
+	var movements []model.CreditMovement
+	for key, value := range stub.writes {
+		if !strings.HasPrefix(key, movementPrefix) {
+			continue
+		}
+		var movement model.CreditMovement
+		err = byteToObject(value, &movement)
+		if err != nil {
+			t.Fatalf("unable to read the credit movement: %v", err)
+		}
+		movements = append(movements, movement)
+	}
+	if len(movements) != 1 || movements[0].Amount != expected-100 || movements[0].Balance != expected || movements[0].Reason != model.CreditUsage {
+		t.Fatalf("the credit movements are %+v, expected a single usage charge of %d", movements, expected-100)
+	}
+
+	for _, id := range mission.Resources {
+		var resource model.Resource
+		resourceKey, _ := stub.CreateCompositeKey(model.ObjectTypeResource, []string{id})
+		err = byteToObject(stub.writes[resourceKey], &resource)
+		if err != nil {
+			t.Fatalf("unable to read the resource '%s': %v", id, err)
+		}
+		if !resource.Available || resource.Consumer != "" {
+			t.Fatalf("the resource '%s' is not released", id)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequestVersion version of the request envelope understood by the chaincode
+const RequestVersion = 1
+
+// RequestFunction name of the chaincode function receiving the request envelope (JSON) as single argument
+const RequestFunction = "request"
+
+// Request envelope of a request sent to the chaincode, the arguments are given by name
+type Request struct {
+	Version int               `json:"version"`
+	Action  string            `json:"action"`
+	Args    map[string]string `json:"args,omitempty"`
+}
+
+// Argument of an action, a required argument can't be empty and a non empty value must follow the validation rule
+// of the kind of the argument (see Validate)
+type Argument struct {
+	Name     string
+	Required bool
+	Kind     string
+}
+
+// Action definition of a request handled by the chaincode: the actor types allowed to perform it,
+// whether it modify the ledger (update) or only read it (query) and the arguments it expects.
+type Action struct {
+	Name  string
+	Roles []string
+	Write bool
+	Args  []Argument
+}
+
+// Arguments that are shared by several actions
+var (
+	argumentResourceID = Argument{Name: "id", Required: true, Kind: KindID}
+	argumentRevision   = Argument{Name: "revision"}
+	argumentTeamID     = Argument{Name: "team", Required: true, Kind: KindID}
+	argumentConsumerID = Argument{Name: "consumer", Required: true, Kind: KindActorID}
+	argumentDelegateID = Argument{Name: "delegate", Required: true, Kind: KindActorID}
+	argumentLocationID = Argument{Name: "location", Kind: KindID}
+	argumentMissionID  = Argument{Name: "mission", Required: true, Kind: KindID}
+)
+
+// Actions list of every action of the chaincode
+// It is the single definition used by the chaincode to route and check the requests, and by the application
+// to build them, so a new action only has to be declared here and implemented in the chaincode.
+var Actions = []Action{
+	// Queries
+	{Name: "actor", Roles: ActorTypes},
+	{Name: "admin", Roles: []string{ActorAdmin}},
+	{Name: "manager", Roles: []string{ActorManager}},
+	{Name: "auditor", Roles: []string{ActorAuditor}},
+	{Name: "consumer", Roles: []string{ActorConsumer}},
+	{Name: "consumers", Roles: ActorTypes},
+	{Name: "actors", Roles: []string{ActorAdmin, ActorAuditor}},
+	{Name: "delegations", Roles: []string{ActorConsumer}},
+	{Name: "teams", Roles: ActorTypes},
+	{Name: "resources", Roles: ActorTypes, Args: []Argument{{Name: "filter", Required: true}, argumentLocationID}},
+	{Name: "resources-by-id", Roles: ActorTypes, Args: []Argument{{Name: "ids", Required: true}}},
+	{Name: "resources-deleted", Roles: []string{ActorAdmin, ActorManager, ActorAuditor}},
+	{Name: "resource", Roles: []string{ActorAdmin, ActorManager, ActorAuditor}, Args: []Argument{argumentResourceID}},
+	{Name: "resources-at", Roles: []string{ActorAdmin, ActorAuditor}, Args: []Argument{{Name: "time", Required: true}}},
+	{Name: "resource-missions", Roles: ActorTypes},
+	{Name: "schema", Roles: []string{ActorAdmin}},
+	{Name: "locations", Roles: ActorTypes},
+	{Name: "missions", Roles: ActorTypes},
+	{Name: "mission", Roles: ActorTypes, Args: []Argument{argumentMissionID}},
+	{Name: "balance", Roles: []string{ActorAdmin, ActorAuditor, ActorConsumer}, Args: []Argument{{Name: "consumer", Kind: KindActorID}}},
+	{Name: "credit-movements", Roles: []string{ActorAdmin, ActorAuditor, ActorConsumer}, Args: []Argument{{Name: "consumer", Kind: KindActorID}}},
+	{Name: "reservations", Roles: ActorTypes, Args: []Argument{{Name: "id", Kind: KindID}}},
+	{Name: "audit-log", Roles: []string{ActorAdmin, ActorAuditor}, Args: []Argument{{Name: "actor", Kind: KindActorID}, {Name: "action", Kind: KindID}, {Name: "from"}, {Name: "to"}}},
+	{Name: "stale-holdings", Roles: []string{ActorAdmin}, Args: []Argument{{Name: "window"}}},
+	{Name: "stats", Roles: ActorTypes},
+
+	// Updates
+	{Name: "register", Roles: ActorTypes, Write: true, Args: []Argument{{Name: "name", Required: true, Kind: KindName}}},
+	{Name: "add", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentResourceID, {Name: "description", Required: true, Kind: KindDescription}, {Name: "shareable"}, argumentLocationID}},
+	{Name: "edit", Roles: []string{ActorAdmin, ActorManager}, Write: true, Args: []Argument{argumentResourceID, {Name: "description", Required: true, Kind: KindDescription}, argumentRevision}},
+	{Name: "delete", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentResourceID, argumentRevision}},
+	{Name: "acquire", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentResourceID, {Name: "team", Kind: KindID}, {Name: "delegator", Kind: KindActorID}, argumentRevision, {Name: "priority"}, {Name: "mission", Kind: KindID}}},
+	{Name: "release", Roles: []string{ActorAdmin, ActorManager, ActorConsumer}, Write: true, Args: []Argument{argumentResourceID, argumentRevision, {Name: "condition"}, {Name: "notes", Kind: KindDescription}, {Name: "meter"}}},
+	{Name: "restore", Roles: []string{ActorAdmin, ActorManager}, Write: true, Args: []Argument{argumentResourceID, argumentRevision}},
+	{Name: "share", Roles: []string{ActorAdmin, ActorManager}, Write: true, Args: []Argument{argumentResourceID, {Name: "shareable", Required: true}, argumentRevision}},
+	{Name: "move", Roles: []string{ActorAdmin, ActorManager}, Write: true, Args: []Argument{argumentResourceID, argumentLocationID, argumentRevision}},
+	{Name: "set-endorsement", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentResourceID, {Name: "orgs"}, argumentRevision}},
+	{Name: "set-companions", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentResourceID, {Name: "companions"}, argumentRevision}},
+	{Name: "renew", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentResourceID, argumentRevision}},
+	{Name: "check-in", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentResourceID}},
+	{Name: "reserve", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentResourceID, {Name: "start", Required: true}, {Name: "end", Required: true}, {Name: "rule"}, {Name: "team", Kind: KindID}, {Name: "time-zone"}}},
+	{Name: "cancel-reservation", Roles: []string{ActorAdmin, ActorManager, ActorConsumer}, Write: true, Args: []Argument{argumentResourceID, {Name: "reservation", Required: true, Kind: KindID}, {Name: "occurrence"}}},
+	{Name: "grant-delegation", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentDelegateID, {Name: "expiration", Required: true}}},
+	{Name: "revoke-delegation", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentDelegateID}},
+	{Name: "add-team", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentTeamID, {Name: "name", Required: true, Kind: KindName}}},
+	{Name: "delete-team", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentTeamID}},
+	{Name: "add-team-member", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentTeamID, argumentConsumerID}},
+	{Name: "remove-team-member", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentTeamID, argumentConsumerID}},
+	{Name: "add-location", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{{Name: "location", Required: true, Kind: KindID}, {Name: "name", Required: true, Kind: KindName}, {Name: "kind", Required: true}, {Name: "parent", Kind: KindID}}},
+	{Name: "delete-location", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{{Name: "location", Required: true, Kind: KindID}}},
+	{Name: "add-mission", Roles: []string{ActorConsumer}, Write: true, Args: []Argument{argumentMissionID, {Name: "title", Required: true, Kind: KindName}, {Name: "start", Required: true}, {Name: "end"}}},
+	{Name: "close-mission", Roles: []string{ActorAdmin, ActorManager, ActorConsumer}, Write: true, Args: []Argument{argumentMissionID}},
+	{Name: "set-priority", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentConsumerID, {Name: "priority"}}},
+	{Name: "allocate-credits", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentConsumerID, {Name: "amount", Required: true}}},
+	{Name: "set-cost", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{argumentResourceID, {Name: "per-use"}, {Name: "per-hour"}, argumentRevision}},
+	{Name: "attach", Roles: []string{ActorAdmin, ActorManager}, Write: true, Args: []Argument{argumentResourceID, {Name: "hash", Required: true, Kind: KindHash}, {Name: "name", Required: true, Kind: KindName}, {Name: "size", Required: true}, {Name: "mime", Kind: KindName}, argumentRevision}},
+	{Name: "migrate", Roles: []string{ActorAdmin}, Write: true, Args: []Argument{{Name: "batch-size"}, {Name: "org"}}},
+}
+
+// Permissions list for every action of the chaincode (query and update) the actor types allowed to perform it
+var Permissions = actionsPermissions()
+
+// actionsPermissions build the permission table from the actions definition
+func actionsPermissions() map[string][]string {
+	permissions := make(map[string][]string)
+	for _, action := range Actions {
+		permissions[action.Name] = action.Roles
+	}
+	return permissions
+}
+
+// FindAction retrieve the definition of an action from its name
+func FindAction(name string) (Action, bool) {
+	for _, action := range Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return Action{}, false
+}
+
+// Positional convert the arguments given by name to the list of arguments in the order of the definition
+// An error is returned if an argument is unknown, if a required argument is missing or if an argument doesn't
+// follow the validation rule of its kind, an optional argument missing is given as an empty string.
+func (a Action) Positional(args map[string]string) ([]string, error) {
+	positional := make([]string, len(a.Args))
+	known := make(map[string]bool)
+	for i, argument := range a.Args {
+		known[argument.Name] = true
+		positional[i] = args[argument.Name]
+		if argument.Required && strings.TrimSpace(positional[i]) == "" {
+			return nil, fmt.Errorf("the argument '%s' of the action '%s' is required", argument.Name, a.Name)
+		}
+		err := Validate(argument.Kind, positional[i])
+		if err != nil {
+			return nil, fmt.Errorf("the argument '%s' of the action '%s' is invalid: %v", argument.Name, a.Name, err)
+		}
+	}
+	for name := range args {
+		if !known[name] {
+			return nil, fmt.Errorf("the argument '%s' is unknown for the action '%s'", name, a.Name)
+		}
+	}
+	return positional, nil
+}
+
+// Named convert a list of arguments in the order of the definition to the arguments by name
+func (a Action) Named(args []string) (map[string]string, error) {
+	if len(args) > len(a.Args) {
+		return nil, fmt.Errorf("the action '%s' expect at most %d arguments", a.Name, len(a.Args))
+	}
+	named := make(map[string]string)
+	for i, arg := range args {
+		named[a.Args[i].Name] = arg
+	}
+	return named, nil
+}
+
+// NewRequest build the request of an action with the arguments given by name
+func NewRequest(action string, args map[string]string) Request {
+	return Request{Version: RequestVersion, Action: action, Args: args}
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// AttachmentMaxSize size in bytes of the largest document that can be attached to a resource
+const AttachmentMaxSize = 32 << 20
+
+// Attachment document attached to a resource (manual, warranty, inspection photo...)
+// The document itself is kept off the ledger, in the content-addressed store of the web application, the ledger
+// only records its SHA-256 hash (lowercase hexadecimal), so it proves which version of the document was attached.
+// Attaching a document again with the same name adds a new version, the previous ones are kept.
+type Attachment struct {
+	Hash        string    `json:"hash"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	MimeType    string    `json:"mimeType,omitempty"`
+	Attacher    string    `json:"attacher"`
+	Time        time.Time `json:"time"`
+	Transaction string    `json:"transaction"`
+}
+
+// FindAttachment retrieve the latest attachment of the resource with the hash given
+func (r *Resource) FindAttachment(hash string) (*Attachment, bool) {
+	for i := len(r.Attachments) - 1; i >= 0; i-- {
+		if r.Attachments[i].Hash == hash {
+			return &r.Attachments[i], true
+		}
+	}
+	return nil, false
+}
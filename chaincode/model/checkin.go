@@ -0,0 +1,57 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// StaleHoldingWindow time after which a holding without check-in of its holder is stale, if no window is given
+const StaleHoldingWindow = 72 * time.Hour
+
+// LastCheckIn retrieve the last time the holder of the resource showed up, the acquisition counts as a check-in
+// It is nil if the resource is available or if the time of the acquisition is unknown.
+func (r *Resource) LastCheckIn() *time.Time {
+	if r.Available {
+		return nil
+	}
+	if r.CheckedInAt != nil && (r.AcquiredAt == nil || r.CheckedInAt.After(*r.AcquiredAt)) {
+		return r.CheckedInAt
+	}
+	return r.AcquiredAt
+}
+
+// IsStale check whether the holder of the resource didn't check in within the window before the time given
+// The companions of a bundle are never stale on their own, they follow the resource they are acquired with.
+func (r *Resource) IsStale(now time.Time, window time.Duration) bool {
+	if r.Available || r.Bundle != "" {
+		return false
+	}
+	lastCheckIn := r.LastCheckIn()
+	return lastCheckIn == nil || lastCheckIn.Add(window).Before(now)
+}
+
+// ParseStaleWindow parse the window of the stale holdings (a duration such as "72h"), the default one if empty
+func ParseStaleWindow(value string) (time.Duration, error) {
+	if value == "" {
+		return StaleHoldingWindow, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("the window '%s' is not a positive duration", value)
+	}
+	return window, nil
+}
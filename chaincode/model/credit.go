@@ -0,0 +1,57 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "time"
+
+// List of the reasons of the credit movements
+const (
+	CreditAllocation  = "allocation"
+	CreditAcquisition = "acquisition"
+	CreditUsage       = "usage"
+)
+
+// Account credit balance of a consumer, charged when acquiring and holding the resources having a cost
+// A consumer without account has a balance of zero.
+type Account struct {
+	Consumer string `json:"consumer"`
+	Balance  int64  `json:"balance"`
+}
+
+// CreditMovement change of the balance of a consumer, the movements of a consumer are only appended by the chaincode
+// The amount is negative for a debit, the balance is the one of the account after the movement.
+type CreditMovement struct {
+	Consumer    string    `json:"consumer"`
+	Amount      int64     `json:"amount"`
+	Balance     int64     `json:"balance"`
+	Reason      string    `json:"reason"`
+	Resource    string    `json:"resource,omitempty"`
+	Transaction string    `json:"transaction"`
+	Time        time.Time `json:"time"`
+}
+
+// UsageHours number of hours charged for holding a resource between the two given times, every hour started is
+// charged so that the first hour is charged as soon as the resource is acquired
+func UsageHours(from time.Time, to time.Time) int64 {
+	if from.IsZero() || !to.After(from) {
+		return 0
+	}
+	duration := to.Sub(from)
+	hours := int64(duration / time.Hour)
+	if duration%time.Hour != 0 {
+		hours++
+	}
+	return hours
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "fmt"
+
+// List of the kinds of location, from the root of the tree to its leaves
+const (
+	LocationSite     = "site"
+	LocationBuilding = "building"
+	LocationRoom     = "room"
+)
+
+// LocationKinds list of every kind of location, each kind is located in the previous one
+var LocationKinds = []string{LocationSite, LocationBuilding, LocationRoom}
+
+// Location place where resources are located, part of the location tree managed by the admins
+// A site has no parent, a building is located in a site and a room in a building.
+type Location struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Parent string `json:"parent,omitempty"`
+}
+
+// Locations list of locations
+type Locations []Location
+
+// ParentKind retrieve the kind of location expected as parent of the given kind, empty for a site
+func ParentKind(kind string) (string, error) {
+	for i, locationKind := range LocationKinds {
+		if locationKind == kind {
+			if i == 0 {
+				return "", nil
+			}
+			return LocationKinds[i-1], nil
+		}
+	}
+	return "", fmt.Errorf("the location kind '%s' is unknown", kind)
+}
+
+// Find retrieve a location of the list by its ID
+func (l Locations) Find(id string) (Location, bool) {
+	for _, location := range l {
+		if location.ID == id {
+			return location, true
+		}
+	}
+	return Location{}, false
+}
+
+// Path retrieve the locations from the root of the tree to the given location (breadcrumb)
+func (l Locations) Path(id string) Locations {
+	var path Locations
+	for id != "" && len(path) < len(LocationKinds) {
+		location, found := l.Find(id)
+		if !found {
+			break
+		}
+		path = append(Locations{location}, path...)
+		id = location.Parent
+	}
+	return path
+}
+
+// Subtree retrieve the IDs of the given location and of every location it contains
+func (l Locations) Subtree(id string) map[string]bool {
+	subtree := map[string]bool{id: true}
+	// The tree has a fixed depth, so a pass by level is enough to reach every descendant
+	for range LocationKinds {
+		for _, location := range l {
+			if subtree[location.Parent] {
+				subtree[location.ID] = true
+			}
+		}
+	}
+	return subtree
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// List of the status of a mission
+const (
+	MissionOpen   = "open"
+	MissionClosed = "closed"
+)
+
+// Mission for which consumers acquire resources, the resources acquired for an open mission are linked to it
+// The owner is the ID of the consumer that created the mission, only the owner can acquire resources for the mission
+// and close it (the admins and managers of its organization can close it too), closing a mission releases every
+// resource still acquired for it. The resources are the IDs of every resource acquired for the mission.
+type Mission struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Owner     string     `json:"owner"`
+	Org       string     `json:"org"`
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end,omitempty"`
+	Status    string     `json:"status"`
+	Created   time.Time  `json:"created"`
+	Closed    *time.Time `json:"closed,omitempty"`
+	Resources []string   `json:"resources,omitempty"`
+}
+
+// IsOpen check whether resources can still be acquired for the mission at the given time
+func (m *Mission) IsOpen(at time.Time) bool {
+	return m.Status == MissionOpen && (m.End == nil || at.Before(*m.End))
+}
+
+// HasResource check whether the given resource ID was acquired for the mission
+func (m *Mission) HasResource(resourceID string) bool {
+	for _, id := range m.Resources {
+		if id == resourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// List of the events of the timeline of a mission
+const (
+	MissionEventCreated   = "created"
+	MissionEventAcquired  = "acquired"
+	MissionEventReleased  = "released"
+	MissionEventPreempted = "preempted"
+	MissionEventClosed    = "closed"
+)
+
+// MissionEvent event of the timeline of a mission, the resource is empty for the events of the mission itself
+type MissionEvent struct {
+	Time        time.Time `json:"time"`
+	Event       string    `json:"event"`
+	Resource    string    `json:"resource,omitempty"`
+	Consumer    string    `json:"consumer,omitempty"`
+	Transaction string    `json:"transaction,omitempty"`
+}
+
+// MissionDetail mission with the resources still acquired for it and its timeline (in chronological order)
+type MissionDetail struct {
+	Mission  Mission        `json:"mission"`
+	Acquired []Resource     `json:"acquired"`
+	Timeline []MissionEvent `json:"timeline"`
+}
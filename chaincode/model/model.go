@@ -15,13 +15,19 @@
 package model
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
 )
 
-// Actor metadata used for an admin and a consumer
+// Actor metadata used for every type of actor (admin, manager, auditor and consumer)
 type Actor struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	Org  string `json:"org"`
+	Type string `json:"type,omitempty"`
 }
 
 // Available actor type
@@ -29,25 +35,188 @@ const (
 	ActorAttribute = "actor"
 	ActorConsumer  = "consumer"
 	ActorAdmin     = "admin"
+	ActorManager   = "manager"
+	ActorAuditor   = "auditor"
 )
 
+// ActorTypes list of every actor type available
+var ActorTypes = []string{ActorAdmin, ActorManager, ActorAuditor, ActorConsumer}
+
 // Admin that manage resources available
 type Admin struct {
 	Actor
 }
 
+// Manager that maintain resources (edit, share and force release) without being able to add or delete them
+type Manager struct {
+	Actor
+}
+
+// Auditor that have a read-only access to every resource, including histories and deleted resources
+type Auditor struct {
+	Actor
+}
+
 // Consumer that acquire and release some resources
+// The priority is the highest priority level of acquisition granted by an admin to the consumer, the CA certificate
+// of the consumer may grant a higher one (see PriorityAttribute).
 type Consumer struct {
 	Actor
+	Priority int `json:"priority,omitempty"`
+}
+
+// Team of consumers sharing their holdings, managed by the admins of its organization
+// Any member of the team can release or renew a resource acquired on behalf of the team.
+type Team struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Org     string   `json:"org"`
+	Members []string `json:"members"`
+}
+
+// HasMember check whether the given consumer ID is a member of the team
+func (t *Team) HasMember(consumerID string) bool {
+	for _, member := range t.Members {
+		if member == consumerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Delegation time-limited right granted by a consumer (the delegator) to another consumer (the delegate)
+// to acquire and release resources on its behalf.
+type Delegation struct {
+	Delegator     string    `json:"delegator"`
+	DelegatorName string    `json:"delegatorName"`
+	Delegate      string    `json:"delegate"`
+	DelegateName  string    `json:"delegateName"`
+	Expiration    time.Time `json:"expiration"`
+}
+
+// IsActive check whether the delegation is still active at the given time
+func (d Delegation) IsActive(at time.Time) bool {
+	return at.Before(d.Expiration)
+}
+
+// Delegations list of the delegations granted by a consumer
+type Delegations []Delegation
+
+// IsActorType check whether the given actor type exist
+func IsActorType(actorType string) bool {
+	for _, t := range ActorTypes {
+		if t == actorType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowed check whether the given actor type is allowed to perform the given action
+func IsAllowed(actorType string, action string) bool {
+	for _, t := range Permissions[action] {
+		if t == actorType {
+			return true
+		}
+	}
+	return false
 }
 
 // Resource that is manage by an admin actor and can be acquire and release by a consumer
+// The delegate is the consumer who performed the last acquisition or release on behalf of the consumer, if any.
+// The owner is the MSP ID of the organization that added the resource, only its admins can manage it
+// and only its consumers can acquire it, unless the resource is shareable with the other organizations.
+// The mission is confidential, only its hash is stored in the world state, the mission itself is kept
+// in a private data collection and only filled by the application when the user is authorised to see it.
+// The mission ID is the ID of the mission (see Mission) the resource is acquired for, if any.
+// The revision is incremented on every update of the resource, it allow to detect concurrent updates.
+// The endorsing organizations are the MSP IDs of the organizations whose peers must endorse every change of
+// the resource (key-level endorsement policy), the chaincode endorsement policy applies if there is none.
+// The companions are the IDs of the resources required by the resource (bundle), they are acquired, renewed and
+// released with it, the bundle of a companion acquired that way is the ID of the resource it follows.
+// The location is the ID of the place where the resource is (see Location), empty if it is not located.
+// A resource out of service can't be acquired until an admin or a manager restores it, the report is the condition
+// report given by the consumer when releasing it (only relevant in the state written by the release transaction).
+// The costs are the credits charged to the consumer acquiring the resource (see Account), once per acquisition
+// and for every hour started since the acquisition time.
+// The priority is the level of the current acquisition, a consumer with a priority high enough can preempt the holder
+// (see CanPreempt). The holders preempted are kept in line, the first one first, the first in line is the only one
+// able to acquire the resource until the claim time (set on release) unless the priority is high enough to preempt it.
+// The attachments are the documents attached to the resource, identified by their hash (see Attachment).
+// The check-in time is the last time the holder showed it still uses the resource (see LastCheckIn).
 type Resource struct {
-	ID          string `json:"id"`
-	Description string `json:"description"`
-	Available   bool   `json:"available"`
-	Mission     string `json:"mission,omitempty"`
-	Consumer    string `json:"consumer,omitempty"`
+	ID            string           `json:"id"`
+	Description   string           `json:"description"`
+	Owner         string           `json:"owner"`
+	Shareable     bool             `json:"shareable"`
+	Available     bool             `json:"available"`
+	Mission       string           `json:"mission,omitempty"`
+	MissionHash   string           `json:"missionHash,omitempty"`
+	MissionID     string           `json:"missionId,omitempty"`
+	Consumer      string           `json:"consumer,omitempty"`
+	Team          string           `json:"team,omitempty"`
+	Delegate      string           `json:"delegate,omitempty"`
+	Revision      uint64           `json:"revision"`
+	EndorsingOrgs []string         `json:"endorsingOrgs,omitempty"`
+	Companions    []string         `json:"companions,omitempty"`
+	Bundle        string           `json:"bundle,omitempty"`
+	Location      string           `json:"location,omitempty"`
+	OutOfService  bool             `json:"outOfService,omitempty"`
+	Report        *ConditionReport `json:"report,omitempty"`
+	CostPerUse    int64            `json:"costPerUse,omitempty"`
+	CostPerHour   int64            `json:"costPerHour,omitempty"`
+	AcquiredAt    *time.Time       `json:"acquiredAt,omitempty"`
+	Priority      int              `json:"priority,omitempty"`
+	Preempted     []Preemption     `json:"preempted,omitempty"`
+	ClaimedUntil  *time.Time       `json:"claimedUntil,omitempty"`
+	CheckedInAt   *time.Time       `json:"checkedInAt,omitempty"`
+	Attachments   []Attachment     `json:"attachments,omitempty"`
+}
+
+// List of the conditions of a resource reported on release
+const (
+	ConditionOK           = "ok"
+	ConditionDamaged      = "damaged"
+	ConditionMissingParts = "missing-parts"
+)
+
+// Conditions list of every condition that can be reported
+var Conditions = []string{ConditionOK, ConditionDamaged, ConditionMissingParts}
+
+// ConditionReport condition of a resource reported by the consumer releasing it
+// The meter is the reading of the usage meter of the resource (hours, kilometres...), if it has one. The
+// transaction is the ID of the release transaction, so that the report is only shown with this transaction.
+type ConditionReport struct {
+	Condition   string   `json:"condition"`
+	Notes       string   `json:"notes,omitempty"`
+	Meter       *float64 `json:"meter,omitempty"`
+	Transaction string   `json:"transaction"`
+}
+
+// ResourceMission private details of the mission of a resource acquired, stored in the private data collection of
+// the owner organization (see MissionsCollection)
+// The salt is the secret key of the hash of the mission recorded in the world state, so that the hash can't be used
+// to confirm a guess of the mission.
+type ResourceMission struct {
+	ResourceID string `json:"resourceId"`
+	Mission    string `json:"mission"`
+	Consumer   string `json:"consumer"`
+	Salt       []byte `json:"salt,omitempty"`
+}
+
+// MissionSaltSize minimum size in bytes of the salt of a mission
+const MissionSaltSize = 16
+
+// MissionHash compute the hash of a mission as stored in the world state, an HMAC-SHA256 keyed by its salt
+// The missions recorded before the salt have a plain SHA-256 hash until they are migrated.
+func MissionHash(mission string, salt []byte) string {
+	if len(salt) == 0 {
+		hash := sha256.Sum256([]byte(mission))
+		return hex.EncodeToString(hash[:])
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(mission))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // ResourceHistory is a detailed information about a resource state in the ledger
@@ -68,14 +237,115 @@ func (a ResourceHistories) Less(i, j int) bool { return a[i].Time.After(a[j].Tim
 // ResourcesDeleted list of resources deleted
 type ResourcesDeleted []Resource
 
+// Schema version of the data stored in the ledger, that is the number of migrations applied
+// The bookmark is the last key processed by the migration in progress, if it didn't complete in a single batch.
+// A private migration is run by organization, the bookmark of each organization started is kept in the orgs (by MSP
+// ID), empty once the organization is migrated.
+// The latest version is the one expected by the chaincode and private tell whether the migration in progress is a
+// private one, they are only filled when the schema is queried.
+type Schema struct {
+	Version  int               `json:"version"`
+	Bookmark string            `json:"bookmark,omitempty"`
+	Orgs     map[string]string `json:"orgs,omitempty"`
+	Latest   int               `json:"latest,omitempty"`
+	Private  bool              `json:"private,omitempty"`
+}
+
+// IsOrgMigrated check whether the private migration in progress is complete for the given organization (MSP ID)
+func (s Schema) IsOrgMigrated(mspID string) bool {
+	bookmark, started := s.Orgs[mspID]
+	return started && bookmark == ""
+}
+
+// IsUpToDate check whether every migration expected by the chaincode is applied
+func (s Schema) IsUpToDate() bool {
+	return s.Version >= s.Latest
+}
+
+// AuditEntry record of an update made in the ledger, the audit log is only appended by the chaincode
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Actor       string    `json:"actor"`
+	ActorType   string    `json:"actorType"`
+	Action      string    `json:"action"`
+	Resource    string    `json:"resource,omitempty"`
+	Transaction string    `json:"transaction"`
+}
+
+// AuditTimeLayout layout of the time in the keys of the audit log, with a fixed width so the keys sort by time
+const AuditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
 // List of object type stored in the ledger
 const (
+	ObjectTypeSchema           = "schema"
 	ObjectTypeAdmin            = "admin"
+	ObjectTypeManager          = "manager"
+	ObjectTypeAuditor          = "auditor"
 	ObjectTypeConsumer         = "consumer"
+	ObjectTypeTeam             = "team"
+	ObjectTypeDelegations      = "delegations"
 	ObjectTypeResource         = "resource"
 	ObjectTypeResourcesDeleted = "resources-deleted"
+	ObjectTypeResourceMission  = "resource-mission"
+	ObjectTypeAuditEntry       = "audit-entry"
+	ObjectTypeReservation      = "reservation"
+	ObjectTypeLocation         = "location"
+	ObjectTypeAccount          = "account"
+	ObjectTypeCreditMovement   = "credit-movement"
+	ObjectTypeMission          = "mission"
+	ObjectTypeStatsCounter     = "stats-counter"
 )
 
+// ErrorRevisionConflict is part of the error details returned when a resource changed since the revision expected
+const ErrorRevisionConflict = "revision conflict"
+
+// List of the codes of the errors returned by the chaincode
+const (
+	ErrorNotFound        = "NOT_FOUND"
+	ErrorNotAvailable    = "NOT_AVAILABLE"
+	ErrorForbidden       = "FORBIDDEN"
+	ErrorInvalidArgument = "INVALID_ARGUMENT"
+	ErrorConflict        = "CONFLICT"
+	ErrorNoCredit        = "NO_CREDIT"
+	ErrorInternal        = "INTERNAL"
+)
+
+// Error returned by the chaincode when a request fails, the code allow the application to identify the failure
+// The message is meant to be shown to the user while the details give the underlying cause, if any.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+// Error build the description of the error
+func (e *Error) Error() string {
+	if e.Details == "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Code, e.Message, e.Details)
+}
+
+// CollectionMissions name of the private data collection storing the resource missions of every organization before
+// each organization had its own, it is kept in the collections of the chaincode to migrate its missions
+const CollectionMissions = "collection-missions"
+
+// MissionsCollection name of the private data collection storing the missions of the resources of an organization,
+// only the peers of the organization are members of it
+func MissionsCollection(ownerOrg string) string {
+	if ownerOrg == "" {
+		return CollectionMissions
+	}
+	return CollectionMissions + "-" + ownerOrg
+}
+
+// TransientMission key of the transient map used to give the mission when acquiring a resource
+const TransientMission = "mission"
+
+// TransientMissionSalt key of the transient map used to give the random salt of the hash of the mission (see
+// MissionHash), it is chosen by the client since every endorsing peer must compute the same hash
+const TransientMissionSalt = "mission-salt"
+
 // List of available filter for query resources
 const (
 	ResourcesFilterAll             = "all"
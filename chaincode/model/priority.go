@@ -0,0 +1,82 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// PriorityAttribute name of the attribute of the CA certificates granting a priority level to a consumer
+const PriorityAttribute = "priority"
+
+// List of the priority levels of an acquisition
+const (
+	PriorityRoutine   = 0
+	PriorityUrgent    = 1
+	PriorityEmergency = 2
+)
+
+// Priorities list of every priority level, from the lowest to the highest
+var Priorities = []int{PriorityRoutine, PriorityUrgent, PriorityEmergency}
+
+// PreemptionMargin number of levels an acquisition priority must be above the one of the holder to preempt it
+const PreemptionMargin = 1
+
+// PreemptionClaimDuration time during which the first consumer in line is the only one able to get a resource back
+// once it is released, the line is ignored after that
+const PreemptionClaimDuration = 24 * time.Hour
+
+// Preemption record of a holder that lost a resource to an acquisition with a higher priority
+// The preemptor is the consumer that acquired the resource and the transaction the ID of the acquisition.
+type Preemption struct {
+	Consumer    string    `json:"consumer"`
+	Team        string    `json:"team,omitempty"`
+	Priority    int       `json:"priority"`
+	Preemptor   string    `json:"preemptor"`
+	Transaction string    `json:"transaction"`
+	Time        time.Time `json:"time"`
+}
+
+// CanPreempt check whether an acquisition with the given priority can take a resource held with another priority
+func CanPreempt(priority int, holderPriority int) bool {
+	return priority >= holderPriority+PreemptionMargin
+}
+
+// ParsePriority parse a priority level, an empty value is the routine priority
+func ParsePriority(value string) (int, error) {
+	if value == "" {
+		return PriorityRoutine, nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < PriorityRoutine || priority > Priorities[len(Priorities)-1] {
+		return 0, fmt.Errorf("the priority '%s' is not a level between %d and %d", value, PriorityRoutine, Priorities[len(Priorities)-1])
+	}
+	return priority, nil
+}
+
+// PriorityName name of a priority level shown to the users
+func PriorityName(priority int) string {
+	switch priority {
+	case PriorityRoutine:
+		return "routine"
+	case PriorityUrgent:
+		return "urgent"
+	case PriorityEmergency:
+		return "emergency"
+	}
+	return fmt.Sprintf("level %d", priority)
+}
@@ -0,0 +1,257 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences maximum number of occurrences of a reservation, the expansion of a recurrence rule is bounded
+// so that the chaincode always expand it in the same (short) time on every peer
+const MaxOccurrences = 1000
+
+// List of the frequencies supported in the recurrence rules
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+)
+
+// Reservation booking of a resource for a time slot, repeated if it has a recurrence rule
+// The ID is the ID of the transaction that made the reservation, the cancelled occurrences are given by their
+// start time, the whole series is deleted when it is cancelled. The time zone is the TZID (like "Europe/Paris") the
+// recurrence rule is expanded in, so that the occurrences keep their local time across the daylight saving time
+// changes, the rule is expanded in UTC if it is empty.
+type Reservation struct {
+	ID        string      `json:"id"`
+	Resource  string      `json:"resource"`
+	Consumer  string      `json:"consumer"`
+	Team      string      `json:"team,omitempty"`
+	Start     time.Time   `json:"start"`
+	End       time.Time   `json:"end"`
+	Rule      string      `json:"rule,omitempty"`
+	TimeZone  string      `json:"timeZone,omitempty"`
+	Cancelled []time.Time `json:"cancelled,omitempty"`
+}
+
+// Occurrence time slot of a reservation
+type Occurrence struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains check whether the given time is part of the occurrence (start included, end excluded)
+func (o Occurrence) Contains(at time.Time) bool {
+	return !at.Before(o.Start) && at.Before(o.End)
+}
+
+// Overlaps check whether two occurrences share a part of their time slot
+func (o Occurrence) Overlaps(other Occurrence) bool {
+	return o.Start.Before(other.End) && other.Start.Before(o.End)
+}
+
+// Recurrence subset of the RFC 5545 recurrence rule (RRULE): a daily, weekly or monthly frequency with an
+// interval, ended by a date (UNTIL) or a number of occurrences (COUNT)
+type Recurrence struct {
+	Frequency string
+	Interval  int
+	Until     time.Time
+	Count     int
+}
+
+// ParseRecurrence parse a recurrence rule like "FREQ=WEEKLY;INTERVAL=1;UNTIL=20181231T235959Z"
+// The "RRULE:" prefix is optional, the rule must have an end (UNTIL or COUNT) and no other part is supported.
+func ParseRecurrence(rule string) (Recurrence, error) {
+	recurrence := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		nameValue := strings.SplitN(part, "=", 2)
+		if len(nameValue) != 2 || nameValue[1] == "" {
+			return Recurrence{}, fmt.Errorf("the part '%s' of the recurrence rule is invalid", part)
+		}
+		var err error
+		switch name, value := strings.ToUpper(nameValue[0]), nameValue[1]; name {
+		case "FREQ":
+			recurrence.Frequency = strings.ToUpper(value)
+			if recurrence.Frequency != FrequencyDaily && recurrence.Frequency != FrequencyWeekly && recurrence.Frequency != FrequencyMonthly {
+				return Recurrence{}, fmt.Errorf("the frequency '%s' is not supported", value)
+			}
+		case "INTERVAL":
+			recurrence.Interval, err = strconv.Atoi(value)
+			if err != nil || recurrence.Interval < 1 {
+				return Recurrence{}, fmt.Errorf("the interval '%s' is not a positive number", value)
+			}
+		case "COUNT":
+			recurrence.Count, err = strconv.Atoi(value)
+			if err != nil || recurrence.Count < 1 {
+				return Recurrence{}, fmt.Errorf("the count '%s' is not a positive number", value)
+			}
+		case "UNTIL":
+			recurrence.Until, err = time.Parse("20060102T150405Z", value)
+			if err != nil {
+				recurrence.Until, err = time.Parse("20060102", value)
+				recurrence.Until = recurrence.Until.Add(24*time.Hour - time.Second)
+			}
+			if err != nil {
+				return Recurrence{}, fmt.Errorf("the end date '%s' is not a valid UTC date", value)
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("the part '%s' of the recurrence rule is not supported", name)
+		}
+	}
+	if recurrence.Frequency == "" {
+		return Recurrence{}, fmt.Errorf("the recurrence rule has no frequency")
+	}
+	if recurrence.Until.IsZero() == (recurrence.Count == 0) {
+		return Recurrence{}, fmt.Errorf("the recurrence rule must end with either an end date or a number of occurrences")
+	}
+	return recurrence, nil
+}
+
+// String format the recurrence as a recurrence rule (without prefix)
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Frequency}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Location retrieve the location of the time zone of the reservation, UTC if it has none
+// The local time zone is refused since it depends on the peer expanding the reservation.
+func (r Reservation) Location() (*time.Location, error) {
+	if r.TimeZone == "" {
+		return time.UTC, nil
+	}
+	if r.TimeZone == "Local" {
+		return nil, fmt.Errorf("the time zone '%s' is not a TZID", r.TimeZone)
+	}
+	location, err := LoadLocation(r.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("the time zone '%s' is unknown: %v", r.TimeZone, err)
+	}
+	return location, nil
+}
+
+// Occurrences expand the reservation in its occurrences ordered by time, without the ones cancelled
+// The occurrences are computed in the time zone of the reservation then given in UTC, a monthly occurrence is
+// skipped in the months without its day (like the 31st), as specified by the RFC 5545. An error is returned if the
+// rule or the time zone is invalid, if the reservation has more than MaxOccurrences occurrences or if its
+// occurrences overlap each other.
+func (r Reservation) Occurrences() ([]Occurrence, error) {
+	start, duration := r.Start.UTC(), r.End.Sub(r.Start)
+	if duration <= 0 {
+		return nil, fmt.Errorf("the end of the reservation is not after its start")
+	}
+	if r.Rule == "" {
+		return r.withoutCancelled([]Occurrence{{Start: start, End: start.Add(duration)}}), nil
+	}
+	recurrence, err := ParseRecurrence(r.Rule)
+	if err != nil {
+		return nil, err
+	}
+	location, err := r.Location()
+	if err != nil {
+		return nil, err
+	}
+	start = start.In(location)
+
+	var occurrences []Occurrence
+	for i := 0; ; i++ {
+		var occurrenceStart time.Time
+		switch recurrence.Frequency {
+		case FrequencyDaily:
+			occurrenceStart = start.AddDate(0, 0, i*recurrence.Interval)
+		case FrequencyWeekly:
+			occurrenceStart = start.AddDate(0, 0, 7*i*recurrence.Interval)
+		case FrequencyMonthly:
+			occurrenceStart = start.AddDate(0, i*recurrence.Interval, 0)
+			if occurrenceStart.Day() != start.Day() {
+				// The month has no such day, the normalized date belongs to the next month
+				continue
+			}
+		}
+		if !recurrence.Until.IsZero() && occurrenceStart.After(recurrence.Until) {
+			break
+		}
+		if recurrence.Count > 0 && len(occurrences) >= recurrence.Count {
+			break
+		}
+		if len(occurrences) >= MaxOccurrences {
+			return nil, fmt.Errorf("the reservation has more than %d occurrences", MaxOccurrences)
+		}
+		occurrenceStart = occurrenceStart.UTC()
+		occurrence := Occurrence{Start: occurrenceStart, End: occurrenceStart.Add(duration)}
+		if len(occurrences) > 0 && occurrences[len(occurrences)-1].Overlaps(occurrence) {
+			return nil, fmt.Errorf("the occurrences of the reservation overlap each other")
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return r.withoutCancelled(occurrences), nil
+}
+
+// withoutCancelled remove the cancelled occurrences from the given ones
+func (r Reservation) withoutCancelled(occurrences []Occurrence) []Occurrence {
+	var kept []Occurrence
+	for _, occurrence := range occurrences {
+		if !r.IsCancelled(occurrence.Start) {
+			kept = append(kept, occurrence)
+		}
+	}
+	return kept
+}
+
+// IsCancelled check whether the occurrence starting at the given time is cancelled
+func (r Reservation) IsCancelled(start time.Time) bool {
+	for _, cancelled := range r.Cancelled {
+		if cancelled.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstConflict find the first occurrence of the reservation that overlaps an occurrence of another one
+// Both lists of occurrences must be ordered by time (see Occurrences).
+func FirstConflict(occurrences []Occurrence, others []Occurrence) (Occurrence, bool) {
+	i, j := 0, 0
+	for i < len(occurrences) && j < len(others) {
+		if occurrences[i].Overlaps(others[j]) {
+			return occurrences[i], true
+		}
+		if occurrences[i].End.After(others[j].End) {
+			j++
+		} else {
+			i++
+		}
+	}
+	return Occurrence{}, false
+}
+
+// Reservations list of reservations (with sorting, first starting first)
+type Reservations []Reservation
+
+func (a Reservations) Len() int           { return len(a) }
+func (a Reservations) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a Reservations) Less(i, j int) bool { return a[i].Start.Before(a[j].Start) }
@@ -0,0 +1,82 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// Dimensions the resources are counted by in the statistics
+const (
+	StatsStatus   = "status"
+	StatsType     = "type"
+	StatsLocation = "location"
+	StatsHolder   = "holder"
+)
+
+// StatsDimensions list of every dimension of the statistics
+var StatsDimensions = []string{StatsStatus, StatsType, StatsLocation, StatsHolder}
+
+// Status of a resource in the statistics, a resource out of service is only counted as such
+const (
+	StatusAvailable    = "available"
+	StatusUnavailable  = "unavailable"
+	StatusOutOfService = "out-of-service"
+)
+
+// Type of a resource in the statistics, whether it is shared with the other organizations
+const (
+	TypeShareable = "shareable"
+	TypePrivate   = "private"
+)
+
+// Stats counts of the resources of the ledger by dimension, then by value of the dimension
+// A resource without location is counted with an empty location, only the resources acquired have a holder.
+type Stats struct {
+	Total  int64                       `json:"total"`
+	Counts map[string]map[string]int64 `json:"counts"`
+}
+
+// StatsCounter shard of the counter of the resources having a value in a dimension
+// A counter is spread over several keys of the ledger so that concurrent transactions rarely update the same one,
+// its count is the sum of the counts of its shards.
+type StatsCounter struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	Count     int64  `json:"count"`
+}
+
+// StatsValues retrieve the value of every dimension the resource is counted in
+func (r *Resource) StatsValues() map[string]string {
+	values := map[string]string{
+		StatsStatus:   StatusAvailable,
+		StatsType:     TypePrivate,
+		StatsLocation: r.Location,
+	}
+	switch {
+	case r.OutOfService:
+		values[StatsStatus] = StatusOutOfService
+	case !r.Available:
+		values[StatsStatus] = StatusUnavailable
+	}
+	if r.Shareable {
+		values[StatsType] = TypeShareable
+	}
+	if !r.Available {
+		values[StatsHolder] = r.Consumer
+	}
+	return values
+}
+
+// Count retrieve the number of resources having the value given in a dimension
+func (s Stats) Count(dimension string, value string) int64 {
+	return s.Counts[dimension][value]
+}
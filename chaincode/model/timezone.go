@@ -0,0 +1,54 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// zoneinfo tz database shipped with the chaincode (a copy of lib/time/zoneinfo.zip of Go), the time zones are never
+// loaded from the peer so that every peer expand the reservations the same way, whatever its own tz database
+//
+//go:embed zoneinfo.zip
+var zoneinfo []byte
+
+// LoadLocation retrieve the location of a time zone (TZID) from the tz database shipped with the chaincode
+func LoadLocation(name string) (*time.Location, error) {
+	reader, err := zip.NewReader(bytes.NewReader(zoneinfo), int64(len(zoneinfo)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the tz database: %v", err)
+	}
+	for _, file := range reader.File {
+		if file.Name != name {
+			continue
+		}
+		zoneReader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the time zone in the tz database: %v", err)
+		}
+		defer zoneReader.Close()
+		zone, err := ioutil.ReadAll(zoneReader)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the time zone in the tz database: %v", err)
+		}
+		return time.LoadLocationFromTZData(name, zone)
+	}
+	return nil, fmt.Errorf("the time zone is not in the tz database")
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// List of the kinds of value validated before being used by the chaincode
+const (
+	KindID          = "id"
+	KindActorID     = "actor-id"
+	KindName        = "name"
+	KindDescription = "description"
+	KindMission     = "mission"
+	KindHash        = "hash"
+)
+
+// ValidationRule rule followed by the values of a kind, shared by the chaincode and the web forms
+// The length is counted in characters, the pattern (if any) must match the whole value and is compatible with
+// the HTML pattern attribute, line breaks and tabs are only accepted in multiline values.
+type ValidationRule struct {
+	MaxLength int
+	Pattern   string
+	Multiline bool
+}
+
+// ValidationRules rules of every kind of value
+// The IDs chosen by the users (resources, teams) are part of the composite keys of the ledger, so they are
+// restricted to a safe charset, the IDs of the actors are computed by the chaincode from their certificate.
+var ValidationRules = map[string]ValidationRule{
+	KindID:          {MaxLength: 64, Pattern: `[A-Za-z0-9][A-Za-z0-9._\-]*`},
+	KindActorID:     {MaxLength: 1024, Pattern: `[A-Za-z0-9+/=]+`},
+	KindName:        {MaxLength: 128},
+	KindDescription: {MaxLength: 512},
+	KindMission:     {MaxLength: 2048, Multiline: true},
+	KindHash:        {MaxLength: 64, Pattern: `[0-9a-f]{64}`},
+}
+
+// validationPatterns patterns of the rules compiled once, by kind
+var validationPatterns = compilePatterns(ValidationRules)
+
+// compilePatterns compile the pattern of every rule having one, anchored to match the whole value
+func compilePatterns(rules map[string]ValidationRule) map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	for kind, rule := range rules {
+		if rule.Pattern != "" {
+			patterns[kind] = regexp.MustCompile("^(?:" + rule.Pattern + ")$")
+		}
+	}
+	return patterns
+}
+
+// Validate check a value against the rule of its kind, an empty value is always valid (see Argument.Required)
+// Every value, whatever its kind, must be valid UTF-8, trimmed and without the delimiters of the composite keys
+// (U+0000 and U+10FFFF) nor control characters.
+func Validate(kind string, value string) error {
+	if value == "" {
+		return nil
+	}
+	rule := ValidationRules[kind]
+	label := kind
+	if label == "" {
+		label = "value"
+	}
+
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("the %s is not valid UTF-8", label)
+	}
+	for _, r := range value {
+		if r == 0 || r == utf8.MaxRune {
+			return fmt.Errorf("the %s contains a reserved character", label)
+		}
+		if unicode.IsControl(r) && !(rule.Multiline && (r == '\n' || r == '\r' || r == '\t')) {
+			return fmt.Errorf("the %s contains a control character", label)
+		}
+	}
+	if value != strings.TrimSpace(value) {
+		return fmt.Errorf("the %s starts or ends with spaces", label)
+	}
+	if rule.MaxLength > 0 && utf8.RuneCountInString(value) > rule.MaxLength {
+		return fmt.Errorf("the %s is longer than %d characters", label, rule.MaxLength)
+	}
+	if pattern := validationPatterns[kind]; pattern != nil && !pattern.MatchString(value) {
+		return fmt.Errorf("the %s contains characters that are not allowed", label)
+	}
+	return nil
+}
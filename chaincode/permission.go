@@ -0,0 +1,54 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// assertPermission check using the permission table that the request owner is allowed to perform the given action
+// The type of the request owner is returned if the action is allowed.
+func assertPermission(stub shim.ChaincodeStubInterface, action string) (string, error) {
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return "", fmt.Errorf("unable to identify the type of the request owner: %v", err)
+	}
+	if !found {
+		return "", fmt.Errorf("the type of the request owner is not present")
+	}
+	if !model.IsAllowed(actorType, action) {
+		return "", fmt.Errorf("the actor type '%s' is not allowed to perform the action '%s'", actorType, action)
+	}
+	return actorType, nil
+}
+
+// actorObjectType retrieve the object type used to store an actor in the ledger from its type
+func actorObjectType(actorType string) (string, error) {
+	switch actorType {
+	case model.ActorAdmin:
+		return model.ObjectTypeAdmin, nil
+	case model.ActorManager:
+		return model.ObjectTypeManager, nil
+	case model.ActorAuditor:
+		return model.ObjectTypeAuditor, nil
+	case model.ActorConsumer:
+		return model.ObjectTypeConsumer, nil
+	default:
+		return "", fmt.Errorf("the actor type '%s' is unknown", actorType)
+	}
+}
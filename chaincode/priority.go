@@ -0,0 +1,102 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+func (t *ResourceManagerChaincode) setPriority(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# set priority of consumer")
+
+	consumerID := args[0]
+
+	priority, err := model.ParsePriority(args[1])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The priority is invalid", err)
+	}
+
+	var consumer model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, consumerID, &consumer)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the consumer in the ledger", err)
+	}
+
+	err = assertOrg(stub, consumer.Org)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the consumer organization is allowed for the kind of request", err)
+	}
+
+	consumer.Priority = priority
+	err = updateInLedger(stub, model.ObjectTypeConsumer, consumerID, consumer)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the consumer in the ledger", err)
+	}
+
+	consumerAsByte, err := objectToByte(consumer)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the consumer to byte", err)
+	}
+
+	fmt.Printf("Consumer priority:\n  Consumer ID -> %s\n  Priority -> %d\n", consumerID, priority)
+
+	return shim.Success(consumerAsByte)
+}
+
+// grantedPriority retrieve the highest priority level the request owner is allowed to acquire a resource with,
+// granted either by the attribute of its certificate or by an admin
+func grantedPriority(stub shim.ChaincodeStubInterface) (int, error) {
+	priority := model.PriorityRoutine
+
+	value, found, err := cid.GetAttributeValue(stub, model.PriorityAttribute)
+	if err != nil {
+		return 0, fmt.Errorf("unable to retrieve the priority attribute of the request owner: %v", err)
+	}
+	if found {
+		priority, err = model.ParsePriority(value)
+		if err != nil {
+			return 0, fmt.Errorf("the priority attribute of the request owner is invalid: %v", err)
+		}
+	}
+
+	consumerID, err := cid.GetID(stub)
+	if err != nil {
+		return 0, fmt.Errorf("unable to identify the ID of the request owner: %v", err)
+	}
+	var consumer model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, consumerID, &consumer)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find the consumer in the ledger: %v", err)
+	}
+	if consumer.Priority > priority {
+		priority = consumer.Priority
+	}
+
+	return priority, nil
+}
+
+// isFirstInLine check whether the given consumer (or its team) is the first in line to get the resource back
+func isFirstInLine(stub shim.ChaincodeStubInterface, resource *model.Resource, consumerID string) bool {
+	if len(resource.Preempted) == 0 {
+		return false
+	}
+	first := resource.Preempted[0]
+	return first.Consumer == consumerID || isTeamMember(stub, first.Team, consumerID)
+}
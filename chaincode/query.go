@@ -20,150 +20,418 @@ import (
 	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"strings"
 	"time"
 )
 
-// query function that handle every readonly in the ledger
-func (t *ResourceManagerChaincode) query(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	fmt.Println("## query")
+func (t *ResourceManagerChaincode) actor(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	// Check whether the number of arguments is sufficient
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	fmt.Println("# actor information")
+
+	actorType, _, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
 
-	if args[0] == "admin" {
-		return t.admin(stub, args[1:])
+	objectType, err := actorObjectType(actorType)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
 
-	if args[0] == "consumer" {
-		return t.consumer(stub, args[1:])
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+	var actor model.Actor
+	err = getFromLedger(stub, objectType, actorID, &actor)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to retrieve the %s in the ledger", actorType), err)
+	}
+	// Actors registered before the type was recorded
+	actor.Type = actorType
+	actorAsByte, err := objectToByte(actor)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable convert the %s to byte", actorType), err)
 	}
 
-	if args[0] == "resources" {
-		return t.resources(stub, args[1:])
+	return shim.Success(actorAsByte)
+}
+
+func (t *ResourceManagerChaincode) consumers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# consumers list")
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeConsumer, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of consumer in the ledger", err)
 	}
 
-	if args[0] == "resources-deleted" {
-		return t.resourcesDeleted(stub, args[1:])
+	consumers := make([]model.Consumer, 0)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a consumer in the ledger", errIt)
+		}
+		var consumer model.Consumer
+		err = byteToObject(keyValueState.Value, &consumer)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a consumer", err)
+		}
+		consumers = append(consumers, consumer)
 	}
 
-	if args[0] == "resource" {
-		return t.resource(stub, args[1:])
+	consumersAsByte, err := objectToByte(consumers)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the consumer list to byte", err)
 	}
 
-	// If the arguments given don’t match any function, we return an error
-	return shim.Error("Unknown query action, check the second argument.")
+	return shim.Success(consumersAsByte)
 }
 
-func (t *ResourceManagerChaincode) admin(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func (t *ResourceManagerChaincode) actors(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	fmt.Println("# admin information")
+	fmt.Println("# actors list")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorAdmin)
+	actors := make([]model.Actor, 0)
+
+	for _, actorType := range model.ActorTypes {
+		objectType, err := actorObjectType(actorType)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to identify the object type of the actors", err)
+		}
+		iterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to retrieve the list of %s in the ledger", actorType), err)
+		}
+		for iterator.HasNext() {
+			keyValueState, errIt := iterator.Next()
+			if errIt != nil {
+				iterator.Close()
+				return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to retrieve a %s in the ledger", actorType), errIt)
+			}
+			var actor model.Actor
+			err = byteToObject(keyValueState.Value, &actor)
+			if err != nil {
+				iterator.Close()
+				return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to convert a %s", actorType), err)
+			}
+			// Actors registered before the type was recorded
+			actor.Type = actorType
+			actors = append(actors, actor)
+		}
+		iterator.Close()
+	}
+
+	actorsAsByte, err := objectToByte(actors)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only admin is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to convert the actor list to byte", err)
 	}
 
-	adminID, err := cid.GetID(stub)
+	return shim.Success(actorsAsByte)
+}
+
+func (t *ResourceManagerChaincode) teams(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# teams list")
+
+	actorType, _, err := cid.GetAttributeValue(stub, model.ActorAttribute)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
-	var admin model.Admin
-	err = getFromLedger(stub, model.ObjectTypeAdmin, adminID, &admin)
+
+	actorID, err := cid.GetID(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve admin in the ledger: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
 	}
-	adminAsByte, err := objectToByte(admin)
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeTeam, []string{})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable convert the admin to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of team in the ledger", err)
+	}
+
+	teams := make([]model.Team, 0)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a team in the ledger", errIt)
+		}
+		var team model.Team
+		err = byteToObject(keyValueState.Value, &team)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a team", err)
+		}
+		// A consumer only see the teams it belongs to
+		if actorType == model.ActorConsumer && !team.HasMember(actorID) {
+			continue
+		}
+		teams = append(teams, team)
+	}
+
+	teamsAsByte, err := objectToByte(teams)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the team list to byte", err)
+	}
+
+	return shim.Success(teamsAsByte)
+}
+
+// getActorTeams retrieve the IDs of the teams the given actor is member of
+func getActorTeams(stub shim.ChaincodeStubInterface, actorID string) (map[string]bool, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeTeam, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the list of team in the ledger: %v", err)
+	}
+
+	actorTeams := make(map[string]bool)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return nil, fmt.Errorf("unable to retrieve a team in the ledger: %v", errIt)
+		}
+		var team model.Team
+		err = byteToObject(keyValueState.Value, &team)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert a team: %v", err)
+		}
+		if team.HasMember(actorID) {
+			actorTeams[team.ID] = true
+		}
 	}
 
-	return shim.Success(adminAsByte)
+	return actorTeams, nil
 }
 
-func (t *ResourceManagerChaincode) consumer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func (t *ResourceManagerChaincode) delegations(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	fmt.Println("# consumer information")
+	fmt.Println("# delegations list")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorConsumer)
+	actorID, err := cid.GetID(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only consumer is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
 	}
 
-	consumerID, err := cid.GetID(stub)
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeDelegations, []string{})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of delegations in the ledger", err)
 	}
-	var consumer model.Consumer
-	err = getFromLedger(stub, model.ObjectTypeConsumer, consumerID, &consumer)
+
+	// The delegations granted by the request owner and the ones granted to it
+	delegations := make(model.Delegations, 0)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve delegations in the ledger", errIt)
+		}
+		var delegatorDelegations model.Delegations
+		err = byteToObject(keyValueState.Value, &delegatorDelegations)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert delegations", err)
+		}
+		for _, delegation := range delegatorDelegations {
+			if delegation.Delegator == actorID || delegation.Delegate == actorID {
+				delegations = append(delegations, delegation)
+			}
+		}
+	}
+
+	delegationsAsByte, err := objectToByte(delegations)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the delegation list to byte", err)
+	}
+
+	return shim.Success(delegationsAsByte)
+}
+
+// getActiveDelegators retrieve the IDs of the consumers that granted an active delegation to the given actor
+func getActiveDelegators(stub shim.ChaincodeStubInterface, actorID string) (map[string]bool, error) {
+	now, err := getTxTime(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve consumer in the ledger: %v", err))
+		return nil, err
 	}
-	clientAsByte, err := objectToByte(consumer)
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeDelegations, []string{})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable convert the consumer to byte: %v", err))
+		return nil, fmt.Errorf("unable to retrieve the list of delegations in the ledger: %v", err)
 	}
 
-	return shim.Success(clientAsByte)
+	actorDelegators := make(map[string]bool)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return nil, fmt.Errorf("unable to retrieve delegations in the ledger: %v", errIt)
+		}
+		var delegations model.Delegations
+		err = byteToObject(keyValueState.Value, &delegations)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert delegations: %v", err)
+		}
+		for _, delegation := range delegations {
+			if delegation.Delegate == actorID && delegation.IsActive(now) {
+				actorDelegators[delegation.Delegator] = true
+			}
+		}
+	}
+
+	return actorDelegators, nil
 }
 
 func (t *ResourceManagerChaincode) resources(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
 	fmt.Println("# resources list")
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
-	}
-
 	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve the list of resource in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
 	}
 
 	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the type of the request owner: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
 	if !found {
-		return shim.Error("The type of the request owner is not present")
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
 	}
 
 	actorID, err := cid.GetID(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	actorTeams, err := getActorTeams(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the teams of the request owner", err)
+	}
+
+	actorDelegators, err := getActiveDelegators(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the delegators of the request owner", err)
 	}
 
 	filter := args[0]
 	resources := make([]model.Resource, 0)
 
+	// The resources can be scoped to a location and every location it contains
+	var subtree map[string]bool
+	if args[1] != "" {
+		locations, err := getLocations(stub)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve the locations in the ledger", err)
+		}
+		if _, found := locations.Find(args[1]); !found {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("The location ID '%s' doesn't exist", args[1]), nil)
+		}
+		subtree = locations.Subtree(args[1])
+	}
+
 	for iterator.HasNext() {
 		keyValueState, errIt := iterator.Next()
 		if errIt != nil {
-			return shim.Error(fmt.Sprintf("Unable to retrieve a resource in the ledger: %v", errIt))
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
 		}
 		var resource model.Resource
 		err = byteToObject(keyValueState.Value, &resource)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable to convert a resource: %v", err))
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
 		}
-		if isResourceCanBeReturned(actorID, actorType, filter, &resource) {
+		if subtree != nil && !subtree[resource.Location] {
+			continue
+		}
+		if isResourceCanBeReturned(actorID, actorType, actorOrg, actorTeams, actorDelegators, filter, &resource) {
 			resources = append(resources, resource)
 		}
 	}
 
 	resourcesAsByte, err := objectToByte(resources)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to convert the resource list to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource list to byte", err)
+	}
+
+	return shim.Success(resourcesAsByte)
+}
+
+// resourcesByID retrieve the resources given (a list of IDs separated by commas) with their companions, the resources
+// that don't exist or that the request owner can't see are left out
+func (t *ResourceManagerChaincode) resourcesByID(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# resources by ID")
+
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
+	}
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
+	}
+
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	actorTeams, err := getActorTeams(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the teams of the request owner", err)
+	}
+
+	actorDelegators, err := getActiveDelegators(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the delegators of the request owner", err)
+	}
+
+	resourceIDs := strings.Split(args[0], ",")
+	given := len(resourceIDs)
+	retrieved := make(map[string]bool)
+	resources := make([]model.Resource, 0)
+	for i := 0; i < len(resourceIDs); i++ {
+		if retrieved[resourceIDs[i]] {
+			continue
+		}
+		retrieved[resourceIDs[i]] = true
+		var resource model.Resource
+		if getFromLedger(stub, model.ObjectTypeResource, resourceIDs[i], &resource) != nil {
+			continue
+		}
+		if !isResourceCanBeReturned(actorID, actorType, actorOrg, actorTeams, actorDelegators, model.ResourcesFilterAll, &resource) {
+			continue
+		}
+		resources = append(resources, resource)
+		if i < given {
+			resourceIDs = append(resourceIDs, resource.Companions...)
+		}
+	}
+
+	resourcesAsByte, err := objectToByte(resources)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource list to byte", err)
 	}
 
 	return shim.Success(resourcesAsByte)
 }
 
 // isResourceCanBeReturned check if the resource can be return to the given actor and filter given.
-func isResourceCanBeReturned(actorID string, actorType string, filter string, resource *model.Resource) bool {
-	// If the request owner is a consumer, we give only available resources or its  previously acquired
-	if model.ActorConsumer == actorType && !resource.Available && resource.Consumer != actorID {
+func isResourceCanBeReturned(actorID string, actorType string, actorOrg string, actorTeams map[string]bool, actorDelegators map[string]bool, filter string, resource *model.Resource) bool {
+	// If the request owner is a consumer, we give only available resources or its (or its teams or its delegators) previously acquired
+	if model.ActorConsumer == actorType && !resource.Available && resource.Consumer != actorID && !actorTeams[resource.Team] && !actorDelegators[resource.Consumer] {
+		return false
+	}
+	// A consumer of another organization can only see the resources shared with it
+	if model.ActorConsumer == actorType && resource.Owner != "" && resource.Owner != actorOrg && !resource.Shareable && resource.Consumer != actorID {
 		return false
 	}
 	if filter == model.ResourcesFilterOnlyAvailable && !resource.Available {
@@ -179,20 +447,15 @@ func (t *ResourceManagerChaincode) resourcesDeleted(stub shim.ChaincodeStubInter
 
 	fmt.Println("# resources deleted list")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorAdmin)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Only admin is allowed for the kind of request: %v", err))
-	}
-
 	var resourcesDeleted model.ResourcesDeleted
-	err = getFromLedger(stub, model.ObjectTypeResourcesDeleted, "", &resourcesDeleted)
+	err := getFromLedger(stub, model.ObjectTypeResourcesDeleted, "", &resourcesDeleted)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve consumer in the ledger: %v", err))
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve consumer in the ledger", err)
 	}
 
 	resourcesDeletedAsByte, err := objectToByte(resourcesDeleted)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to convert the list of resource deleted to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to convert the list of resource deleted to byte", err)
 	}
 
 	return shim.Success(resourcesDeletedAsByte)
@@ -202,41 +465,207 @@ func (t *ResourceManagerChaincode) resource(stub shim.ChaincodeStubInterface, ar
 
 	fmt.Println("# resource detail")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorAdmin)
+	resourceID := args[0]
+
+	resourceHistories, err := getResourceHistories(stub, resourceID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only admin is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the history of the resource in the ledger", err)
 	}
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	if len(resourceHistories) <= 0 {
+		return errorResponse(model.ErrorNotFound, "Unable to found an history for the resource ID given", err)
 	}
 
-	resourceID := args[0]
-	if resourceID == "" {
-		return shim.Error("The resource ID is empty.")
+	resourcesHistoryAsByte, err := objectToByte(resourceHistories)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource histories to byte", err)
 	}
 
+	return shim.Success(resourcesHistoryAsByte)
+}
+
+func (t *ResourceManagerChaincode) resourceMissions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# resource missions list")
+
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
+	}
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
+	}
+
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	actorTeams, err := getActorTeams(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the teams of the request owner", err)
+	}
+
+	actorDelegators, err := getActiveDelegators(stub, actorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the delegators of the request owner", err)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
+	}
+
+	resourceMissions := make([]model.ResourceMission, 0)
+
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
+		}
+		// Resources acquired before the missions were private have no mission in the private data collection
+		if resource.Available || resource.MissionHash == "" {
+			continue
+		}
+		// Only the admins, managers and auditors of the owner organization and the consumers in mission are authorised to see the mission
+		if !(actorType != model.ActorConsumer && assertOwnerOrg(stub, &resource) == nil) && resource.Consumer != actorID && !actorTeams[resource.Team] && !actorDelegators[resource.Consumer] {
+			continue
+		}
+		// The peer queried only store the missions of the organizations it is member of the collection
+		resourceMission, err := getResourceMission(stub, &resource)
+		if err != nil {
+			fmt.Printf("Unable to retrieve the mission of the resource '%s': %v\n", resource.ID, err)
+			continue
+		}
+		resourceMissions = append(resourceMissions, resourceMission)
+	}
+
+	resourceMissionsAsByte, err := objectToByte(resourceMissions)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource missions list to byte", err)
+	}
+
+	return shim.Success(resourceMissionsAsByte)
+}
+
+func (t *ResourceManagerChaincode) schema(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# schema")
+
+	schema, err := getSchema(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the schema", err)
+	}
+
+	schemaAsByte, err := objectToByte(schema)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the schema to byte", err)
+	}
+
+	return shim.Success(schemaAsByte)
+}
+
+func (t *ResourceManagerChaincode) resourcesAt(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# resources at a time")
+
+	at, err := time.Parse(time.RFC3339, args[0])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The time is not a valid RFC 3339 time", err)
+	}
+
+	// The resources existing now and the ones deleted since, a resource ID can be deleted several times
+	var resourceIDs []string
+	known := make(map[string]bool)
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
+		}
+		known[resource.ID] = true
+		resourceIDs = append(resourceIDs, resource.ID)
+	}
+	var resourcesDeleted model.ResourcesDeleted
+	err = getFromLedger(stub, model.ObjectTypeResourcesDeleted, "", &resourcesDeleted)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of deleted resources in the ledger", err)
+	}
+	for _, resource := range resourcesDeleted {
+		if !known[resource.ID] {
+			known[resource.ID] = true
+			resourceIDs = append(resourceIDs, resource.ID)
+		}
+	}
+
+	// The state of a resource at the time is its last change before, unless this change is its deletion
+	resources := make([]model.Resource, 0)
+	for _, resourceID := range resourceIDs {
+		resourceHistories, err := getResourceHistories(stub, resourceID)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to retrieve the history of the resource '%s' in the ledger", resourceID), err)
+		}
+		sort.Sort(resourceHistories)
+		for _, resourceHistory := range resourceHistories {
+			if resourceHistory.Time.After(at) {
+				continue
+			}
+			if !resourceHistory.Deleted {
+				resources = append(resources, resourceHistory.Resource)
+			}
+			break
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].ID < resources[j].ID })
+
+	resourcesAsByte, err := objectToByte(resources)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the resource list to byte", err)
+	}
+
+	fmt.Printf("Resources at %s: %d\n", at.Format(time.RFC3339), len(resources))
+
+	return shim.Success(resourcesAsByte)
+}
+
+// getResourceHistories retrieve every state of a resource in the ledger (in the order of the ledger)
+func getResourceHistories(stub shim.ChaincodeStubInterface, resourceID string) (model.ResourceHistories, error) {
 	key, err := stub.CreateCompositeKey(model.ObjectTypeResource, []string{resourceID})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("unable to create the object key for the ledger: %v", err))
+		return nil, fmt.Errorf("unable to create the object key for the ledger: %v", err)
 	}
 	iterator, err := stub.GetHistoryForKey(key)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("unable to retrieve an history resource in the ledger: %v", err))
+		return nil, fmt.Errorf("unable to retrieve an history resource in the ledger: %v", err)
 	}
+	defer iterator.Close()
 
 	var resourceHistories model.ResourceHistories
 	for iterator.HasNext() {
 		historyState, errIt := iterator.Next()
 		if errIt != nil {
-			return shim.Error(fmt.Sprintf("unable to retrieve a resource history in the ledger: %v", errIt))
+			return nil, fmt.Errorf("unable to retrieve a resource history in the ledger: %v", errIt)
 		}
 		var resourceHistory model.ResourceHistory
 		resourceHistory.Deleted = historyState.GetIsDelete()
 		if !resourceHistory.Deleted {
 			err = byteToObject(historyState.GetValue(), &resourceHistory.Resource)
 			if err != nil {
-				return shim.Error(fmt.Sprintf("unable to convert the resource history value to a valid resource: %v", err))
+				return nil, fmt.Errorf("unable to convert the resource history value to a valid resource: %v", err)
 			}
 		}
 		resourceHistory.Transaction = historyState.GetTxId()
@@ -244,15 +673,5 @@ func (t *ResourceManagerChaincode) resource(stub shim.ChaincodeStubInterface, ar
 		resourceHistory.Time = time.Unix(timestamp.Seconds, int64(timestamp.Nanos))
 		resourceHistories = append(resourceHistories, resourceHistory)
 	}
-
-	if len(resourceHistories) <= 0 {
-		return shim.Error(fmt.Sprintf("Unable to found an history for the resource ID given: %v", err))
-	}
-
-	resourcesHistoryAsByte, err := objectToByte(resourceHistories)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to convert the resource histories to byte: %v", err))
-	}
-
-	return shim.Success(resourcesHistoryAsByte)
+	return resourceHistories, nil
 }
@@ -0,0 +1,338 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"time"
+)
+
+func (t *ResourceManagerChaincode) reservations(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# reservations")
+
+	reservations, err := getReservations(stub, args[0])
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the reservations in the ledger", err)
+	}
+	sort.Sort(reservations)
+
+	reservationsAsByte, err := objectToByte(reservations)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the reservation list to byte", err)
+	}
+
+	return shim.Success(reservationsAsByte)
+}
+
+func (t *ResourceManagerChaincode) reserve(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# reserve resource")
+
+	resourceID := args[0]
+
+	start, err := time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The start of the reservation is not a valid RFC 3339 time", err)
+	}
+	end, err := time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The end of the reservation is not a valid RFC 3339 time", err)
+	}
+	rule := ""
+	if args[3] != "" {
+		recurrence, err := model.ParseRecurrence(args[3])
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The recurrence rule is invalid", err)
+		}
+		rule = recurrence.String()
+	}
+
+	var resource model.Resource
+	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	if !resource.Shareable && assertOwnerOrg(stub, &resource) != nil {
+		return errorResponse(model.ErrorForbidden, fmt.Sprintf("The resource ID '%s' is not shared with your organization", resourceID), nil)
+	}
+
+	consumerID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	// The resource can be reserved on behalf of a team the consumer is member of
+	teamID := args[4]
+	if teamID != "" && !isTeamMember(stub, teamID, consumerID) {
+		return errorResponse(model.ErrorForbidden, fmt.Sprintf("Unable to reserve a resource on behalf of the team '%s' you are not member of", teamID), nil)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	reservation := model.Reservation{
+		ID:       stub.GetTxID(),
+		Resource: resourceID,
+		Consumer: consumerID,
+		Team:     teamID,
+		Start:    start.UTC(),
+		End:      end.UTC(),
+		Rule:     rule,
+		TimeZone: args[5],
+	}
+	_, err = reservation.Location()
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The time zone of the reservation is invalid", err)
+	}
+	occurrences, err := reservation.Occurrences()
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The reservation is invalid", err)
+	}
+	occurrences = upcomingOccurrences(occurrences, txTime)
+	if len(occurrences) == 0 {
+		return errorResponse(model.ErrorInvalidArgument, "The reservation has no occurrence in the future", nil)
+	}
+
+	// The occurrences to come can't overlap the ones of the other reservations of the resource
+	reservations, err := getReservations(stub, resourceID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the reservations in the ledger", err)
+	}
+	for _, other := range reservations {
+		otherOccurrences, err := other.Occurrences()
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to expand the reservation '%s'", other.ID), err)
+		}
+		conflict, found := model.FirstConflict(occurrences, upcomingOccurrences(otherOccurrences, txTime))
+		if found {
+			return errorResponse(model.ErrorConflict, fmt.Sprintf("The occurrence starting at %s overlaps the reservation '%s'", conflict.Start.Format(time.RFC3339), other.ID), nil)
+		}
+	}
+
+	// An acquisition has no end, so it only conflicts with an occurrence in progress
+	if !resource.Available && occurrences[0].Contains(txTime) && resource.Consumer != consumerID && !isTeamMember(stub, resource.Team, consumerID) {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is acquired during the first occurrence of the reservation", resourceID), nil)
+	}
+
+	err = putReservation(stub, reservation)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to store the reservation in the ledger", err)
+	}
+
+	reservationAsByte, err := objectToByte(reservation)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the reservation to byte", err)
+	}
+
+	fmt.Printf("Resource reserved:\n  ID -> %s\n  Reservation ID -> %s\n  Start -> %s\n  End -> %s\n  Rule -> %s\n  Time zone -> %s\n  Occurrences -> %d\n", resourceID, reservation.ID, reservation.Start, reservation.End, reservation.Rule, reservation.TimeZone, len(occurrences))
+
+	return shim.Success(reservationAsByte)
+}
+
+func (t *ResourceManagerChaincode) cancelReservation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# cancel reservation")
+
+	resourceID, reservationID := args[0], args[1]
+
+	key, err := stub.CreateCompositeKey(model.ObjectTypeReservation, []string{resourceID, reservationID})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the reservation key for the ledger", err)
+	}
+	reservationAsByte, err := stub.GetState(key)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the reservation in the ledger", err)
+	}
+	if reservationAsByte == nil {
+		return errorResponse(model.ErrorNotFound, fmt.Sprintf("The reservation '%s' of the resource '%s' doesn't exist", reservationID, resourceID), nil)
+	}
+	var reservation model.Reservation
+	err = byteToObject(reservationAsByte, &reservation)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the reservation", err)
+	}
+
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
+	}
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
+	}
+	switch actorType {
+	case model.ActorAdmin, model.ActorManager:
+		// Admins and managers cancel the reservations of the resources of their organization
+		var resource model.Resource
+		err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+		}
+		err = assertOwnerOrg(stub, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
+		}
+	case model.ActorConsumer:
+		consumerID, err := cid.GetID(stub)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+		}
+		if !isReservationHolder(stub, &reservation, consumerID) {
+			return errorResponse(model.ErrorForbidden, "Unable to cancel a reservation that you or your team don't previously make", nil)
+		}
+	default:
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is unknown", nil)
+	}
+
+	occurrences, err := reservation.Occurrences()
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to expand the reservation", err)
+	}
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+
+	// A single occurrence is cancelled if its start is given, else the whole series
+	if args[2] != "" {
+		occurrenceStart, err := time.Parse(time.RFC3339, args[2])
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The start of the occurrence is not a valid RFC 3339 time", err)
+		}
+		var remaining []model.Occurrence
+		found := false
+		for _, occurrence := range occurrences {
+			if occurrence.Start.Equal(occurrenceStart) {
+				found = true
+			} else {
+				remaining = append(remaining, occurrence)
+			}
+		}
+		if !found {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("The reservation has no occurrence starting at %s", args[2]), nil)
+		}
+		reservation.Cancelled = append(reservation.Cancelled, occurrenceStart.UTC())
+		occurrences = remaining
+	} else {
+		occurrences = nil
+	}
+
+	// The reservation is deleted once it has no occurrence to come, the past ones are no longer needed
+	if len(upcomingOccurrences(occurrences, txTime)) > 0 {
+		err = putReservation(stub, reservation)
+	} else {
+		err = stub.DelState(key)
+	}
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the reservation in the ledger", err)
+	}
+
+	reservationAsByte, err = objectToByte(reservation)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the reservation to byte", err)
+	}
+
+	fmt.Printf("Reservation cancelled:\n  ID -> %s\n  Occurrence -> %s\n  Remaining occurrences -> %d\n", reservationID, args[2], len(occurrences))
+
+	return shim.Success(reservationAsByte)
+}
+
+// getReservations retrieve the reservations of a resource, or of every resource if no resource ID is given
+func getReservations(stub shim.ChaincodeStubInterface, resourceID string) (model.Reservations, error) {
+	var attributes []string
+	if resourceID != "" {
+		attributes = []string{resourceID}
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeReservation, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the reservations in the ledger: %v", err)
+	}
+	defer iterator.Close()
+	reservations := make(model.Reservations, 0)
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return nil, fmt.Errorf("unable to retrieve a reservation in the ledger: %v", errIt)
+		}
+		var reservation model.Reservation
+		err = byteToObject(keyValueState.Value, &reservation)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, nil
+}
+
+// putReservation store a reservation in the ledger, under the key of its resource so they are retrieved together
+func putReservation(stub shim.ChaincodeStubInterface, reservation model.Reservation) error {
+	key, err := stub.CreateCompositeKey(model.ObjectTypeReservation, []string{reservation.Resource, reservation.ID})
+	if err != nil {
+		return fmt.Errorf("unable to create the reservation key for the ledger: %v", err)
+	}
+	reservationAsByte, err := objectToByte(reservation)
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, reservationAsByte)
+	if err != nil {
+		return fmt.Errorf("unable to put the reservation in the ledger: %v", err)
+	}
+	return nil
+}
+
+// getActiveReservation retrieve the reservation of a resource having an occurrence in progress at the given time
+// Nil is returned if the resource is not reserved at this time.
+func getActiveReservation(stub shim.ChaincodeStubInterface, resourceID string, at time.Time) (*model.Reservation, error) {
+	reservations, err := getReservations(stub, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reservations {
+		occurrences, err := reservations[i].Occurrences()
+		if err != nil {
+			return nil, fmt.Errorf("unable to expand the reservation '%s': %v", reservations[i].ID, err)
+		}
+		for _, occurrence := range occurrences {
+			if occurrence.Contains(at) {
+				return &reservations[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// isReservationHolder check whether the reservation was made by the consumer or on behalf of one of its teams
+func isReservationHolder(stub shim.ChaincodeStubInterface, reservation *model.Reservation, consumerID string) bool {
+	return reservation.Consumer == consumerID || (reservation.Team != "" && isTeamMember(stub, reservation.Team, consumerID))
+}
+
+// upcomingOccurrences keep the occurrences that are not over at the given time
+func upcomingOccurrences(occurrences []model.Occurrence, at time.Time) []model.Occurrence {
+	for i, occurrence := range occurrences {
+		if occurrence.End.After(at) {
+			return occurrences[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// handler implementation of an action, it receives the arguments in the order of the action definition
+// (see model.Actions), every required argument is given and every optional argument missing is empty.
+type handler func(stub shim.ChaincodeStubInterface, args []string) pb.Response
+
+// handlers bind every action defined in the model to its implementation
+func (t *ResourceManagerChaincode) handlers() map[string]handler {
+	return map[string]handler{
+		// Queries
+		"actor":             t.actor,
+		"admin":             t.actor,
+		"manager":           t.actor,
+		"auditor":           t.actor,
+		"consumer":          t.actor,
+		"consumers":         t.consumers,
+		"actors":            t.actors,
+		"delegations":       t.delegations,
+		"teams":             t.teams,
+		"resources":         t.resources,
+		"resources-by-id":   t.resourcesByID,
+		"resources-deleted": t.resourcesDeleted,
+		"resource":          t.resource,
+		"resources-at":      t.resourcesAt,
+		"resource-missions": t.resourceMissions,
+		"schema":            t.schema,
+		"locations":         t.locations,
+		"missions":          t.missions,
+		"mission":           t.mission,
+		"reservations":      t.reservations,
+		"balance":           t.balance,
+		"credit-movements":  t.creditMovements,
+		"audit-log":         t.auditLog,
+		"stale-holdings":    t.staleHoldings,
+		"stats":             t.stats,
+
+		// Updates
+		"register":           t.register,
+		"add":                t.add,
+		"edit":               t.edit,
+		"delete":             t.delete,
+		"acquire":            t.acquire,
+		"release":            t.release,
+		"share":              t.share,
+		"restore":            t.restore,
+		"move":               t.move,
+		"set-endorsement":    t.setEndorsement,
+		"set-companions":     t.setCompanions,
+		"renew":              t.renew,
+		"check-in":           t.checkIn,
+		"reserve":            t.reserve,
+		"cancel-reservation": t.cancelReservation,
+		"grant-delegation":   t.grantDelegation,
+		"revoke-delegation":  t.revokeDelegation,
+		"add-team":           t.addTeam,
+		"delete-team":        t.deleteTeam,
+		"add-team-member":    t.addTeamMember,
+		"remove-team-member": t.removeTeamMember,
+		"add-location":       t.addLocation,
+		"delete-location":    t.deleteLocation,
+		"add-mission":        t.addMission,
+		"close-mission":      t.closeMission,
+		"set-priority":       t.setPriority,
+		"allocate-credits":   t.allocateCredits,
+		"set-cost":           t.setCost,
+		"attach":             t.attach,
+		"migrate":            t.migrate,
+	}
+}
+
+// route check a request against the definition of its action and give it to the handler of the action
+func (t *ResourceManagerChaincode) route(stub shim.ChaincodeStubInterface, request model.Request) pb.Response {
+
+	fmt.Printf("## %s (version %d)\n", request.Action, request.Version)
+
+	if request.Version != model.RequestVersion {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The request version %d is not supported, expected %d", request.Version, model.RequestVersion), nil)
+	}
+
+	action, found := model.FindAction(request.Action)
+	handle, implemented := t.handlers()[request.Action]
+	if !found || !implemented {
+		return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("Unknown action '%s'", request.Action), nil)
+	}
+
+	// Check whether the request owner is allowed to perform the action
+	actorType, err := assertPermission(stub, action.Name)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, fmt.Sprintf("The request owner is not allowed to perform the action '%s'", action.Name), err)
+	}
+
+	args, err := action.Positional(request.Args)
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The arguments of the request are invalid", err)
+	}
+
+	// The resources written by an update are recorded to maintain the statistics
+	if !action.Write {
+		return handle(stub, args)
+	}
+	recorder := newResourceRecorder(stub)
+	response := handle(recorder, args)
+
+	if response.Status < shim.ERRORTHRESHOLD {
+		err = updateStats(stub, recorder)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to update the statistics of the resources", err)
+		}
+
+		// Every update applied is recorded in the audit log, in the same transaction
+		var resourceID string
+		for i, argument := range action.Args {
+			if argument.Name == "id" {
+				resourceID = args[i]
+			}
+		}
+		err = appendAuditEntry(stub, actorType, action.Name, resourceID)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to record the update in the audit log", err)
+		}
+	}
+
+	return response
+}
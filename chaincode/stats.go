@@ -0,0 +1,292 @@
+// Copyright 2018 Antoine CHABERT, toHero.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// statsShards number of keys every counter of the statistics is spread over
+const statsShards = 16
+
+// statsMigration index of the migration counting the resources recorded before the statistics (see migrations)
+const statsMigration = 2
+
+// resourceRecorder stub that record the resources written by an update, with their state before the update
+// The statistics are maintained from the changes recorded, whatever the handler writing the resources. The deltas
+// computed by the handler itself (see migrateStats) are kept to be applied with them, since a transaction doesn't
+// read its own writes and two updates of the same shard in a transaction would lose the first one.
+type resourceRecorder struct {
+	shim.ChaincodeStubInterface
+	changes map[string]*resourceChange
+	deltas  statsDeltas
+}
+
+// resourceChange state of a resource before an update and after it, nil if the resource doesn't exist
+type resourceChange struct {
+	before []byte
+	after  []byte
+}
+
+// newResourceRecorder build a stub recording the resources written through the given one
+func newResourceRecorder(stub shim.ChaincodeStubInterface) *resourceRecorder {
+	return &resourceRecorder{ChaincodeStubInterface: stub, changes: make(map[string]*resourceChange), deltas: make(statsDeltas)}
+}
+
+// PutState record the new state of a resource before writing it
+func (r *resourceRecorder) PutState(key string, value []byte) error {
+	err := r.record(key, value)
+	if err != nil {
+		return err
+	}
+	return r.ChaincodeStubInterface.PutState(key, value)
+}
+
+// DelState record the deletion of a resource before deleting it
+func (r *resourceRecorder) DelState(key string) error {
+	err := r.record(key, nil)
+	if err != nil {
+		return err
+	}
+	return r.ChaincodeStubInterface.DelState(key)
+}
+
+// record keep the state written if the key is the one of a resource, with its state in the ledger the first time
+func (r *resourceRecorder) record(key string, after []byte) error {
+	objectType, _, err := r.SplitCompositeKey(key)
+	if err != nil || objectType != model.ObjectTypeResource {
+		return nil
+	}
+	change, found := r.changes[key]
+	if !found {
+		before, err := r.ChaincodeStubInterface.GetState(key)
+		if err != nil {
+			return fmt.Errorf("unable to retrieve the resource '%s' before its update: %v", key, err)
+		}
+		change = &resourceChange{before: before}
+		r.changes[key] = change
+	}
+	change.after = after
+	return nil
+}
+
+// statsDeltas changes of the counters, by dimension then by value
+type statsDeltas map[string]map[string]int64
+
+// add count a resource (or uncount it with a negative count) in every dimension
+func (d statsDeltas) add(resourceAsByte []byte, count int64) error {
+	if resourceAsByte == nil {
+		return nil
+	}
+	var resource model.Resource
+	err := byteToObject(resourceAsByte, &resource)
+	if err != nil {
+		return err
+	}
+	for dimension, value := range resource.StatsValues() {
+		if d[dimension] == nil {
+			d[dimension] = make(map[string]int64)
+		}
+		d[dimension][value] += count
+	}
+	return nil
+}
+
+// merge add the changes of other deltas
+func (d statsDeltas) merge(other statsDeltas) {
+	for dimension, values := range other {
+		if d[dimension] == nil {
+			d[dimension] = make(map[string]int64)
+		}
+		for value, delta := range values {
+			d[dimension][value] += delta
+		}
+	}
+}
+
+// updateStats apply the changes of the resources recorded during an update to the counters of the statistics
+// While the migration counting the resources recorded before the statistics is in progress, only the resources
+// already counted by it are applied, the other ones are counted by the migration in their latest state.
+func updateStats(stub shim.ChaincodeStubInterface, recorder *resourceRecorder) error {
+	if len(recorder.changes) == 0 {
+		return applyStatsDeltas(stub, recorder.deltas)
+	}
+	schema, err := getSchema(stub)
+	if err != nil {
+		return err
+	}
+	if schema.Version < statsMigration {
+		return applyStatsDeltas(stub, recorder.deltas)
+	}
+
+	deltas := recorder.deltas
+	for key, change := range recorder.changes {
+		if schema.Version == statsMigration && key > schema.Bookmark {
+			continue
+		}
+		err = deltas.add(change.before, -1)
+		if err != nil {
+			return fmt.Errorf("unable to convert the resource '%s': %v", key, err)
+		}
+		err = deltas.add(change.after, 1)
+		if err != nil {
+			return fmt.Errorf("unable to convert the resource '%s': %v", key, err)
+		}
+	}
+	return applyStatsDeltas(stub, deltas)
+}
+
+// applyStatsDeltas add the changes to the counters, in the shard of the transaction
+// The transactions are spread over the shards by their ID, so two concurrent transactions only conflict when they
+// change the same counter in the same shard. The statistics query read at most statsShards keys by counter.
+func applyStatsDeltas(stub shim.ChaincodeStubInterface, deltas statsDeltas) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(stub.GetTxID()))
+	shard := strconv.Itoa(int(hash.Sum32() % statsShards))
+
+	// The counters are updated in a stable order, so that every peer endorse the same writes
+	dimensions := make([]string, 0, len(deltas))
+	for dimension := range deltas {
+		dimensions = append(dimensions, dimension)
+	}
+	sort.Strings(dimensions)
+	for _, dimension := range dimensions {
+		values := make([]string, 0, len(deltas[dimension]))
+		for value := range deltas[dimension] {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			delta := deltas[dimension][value]
+			if delta == 0 {
+				continue
+			}
+			key, err := stub.CreateCompositeKey(model.ObjectTypeStatsCounter, []string{dimension, value, shard})
+			if err != nil {
+				return fmt.Errorf("unable to create the key of the counter '%s' of the %s: %v", value, dimension, err)
+			}
+			counter := model.StatsCounter{Dimension: dimension, Value: value}
+			counterAsByte, err := stub.GetState(key)
+			if err != nil {
+				return fmt.Errorf("unable to retrieve the counter '%s' of the %s: %v", value, dimension, err)
+			}
+			if counterAsByte != nil {
+				err = byteToObject(counterAsByte, &counter)
+				if err != nil {
+					return fmt.Errorf("unable to convert the counter '%s' of the %s: %v", value, dimension, err)
+				}
+			}
+			counter.Count += delta
+			counterAsByte, err = objectToByte(counter)
+			if err != nil {
+				return fmt.Errorf("unable to convert the counter '%s' of the %s: %v", value, dimension, err)
+			}
+			err = stub.PutState(key, counterAsByte)
+			if err != nil {
+				return fmt.Errorf("unable to update the counter '%s' of the %s: %v", value, dimension, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateStats count the resources recorded before the statistics
+func migrateStats(stub shim.ChaincodeStubInterface, bookmark string, batchSize int) (int, string, error) {
+	deltas := make(statsDeltas)
+	processed, bookmark, err := migrateObjects(stub, model.ObjectTypeResource, bookmark, batchSize, func(resourceAsByte []byte) ([]byte, error) {
+		return nil, deltas.add(resourceAsByte, 1)
+	})
+	if err != nil {
+		return processed, bookmark, err
+	}
+	// Within an update, the deltas are applied with the ones of the resources written (see updateStats)
+	if recorder, ok := stub.(*resourceRecorder); ok {
+		recorder.deltas.merge(deltas)
+		return processed, bookmark, nil
+	}
+	err = applyStatsDeltas(stub, deltas)
+	if err != nil {
+		return processed, bookmark, err
+	}
+	return processed, bookmark, nil
+}
+
+func (t *ResourceManagerChaincode) stats(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# stats")
+
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
+	}
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeStatsCounter, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the counters of the statistics in the ledger", err)
+	}
+	defer iterator.Close()
+
+	stats := model.Stats{Counts: make(map[string]map[string]int64)}
+	for _, dimension := range model.StatsDimensions {
+		stats.Counts[dimension] = make(map[string]int64)
+	}
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a counter of the statistics in the ledger", errIt)
+		}
+		var counter model.StatsCounter
+		err = byteToObject(keyValueState.Value, &counter)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a counter of the statistics", err)
+		}
+		// The consumers don't know who hold the resources of the other consumers
+		if counter.Dimension == model.StatsHolder && actorType == model.ActorConsumer {
+			continue
+		}
+		if stats.Counts[counter.Dimension] != nil {
+			stats.Counts[counter.Dimension][counter.Value] += counter.Count
+		}
+	}
+
+	// The values no longer counted are left out, every resource has a status
+	for _, values := range stats.Counts {
+		for value, count := range values {
+			if count == 0 {
+				delete(values, value)
+			}
+		}
+	}
+	for _, count := range stats.Counts[model.StatsStatus] {
+		stats.Total += count
+	}
+
+	statsAsByte, err := objectToByte(stats)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to convert the statistics to byte", err)
+	}
+
+	return shim.Success(statsAsByte)
+}
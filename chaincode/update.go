@@ -20,146 +20,172 @@ import (
 	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// update that handle every write in the ledger
-func (t *ResourceManagerChaincode) update(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func (t *ResourceManagerChaincode) register(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	fmt.Println("## update")
+	fmt.Println("# register user")
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
-
-	if args[0] == "register" {
-		return t.register(stub, args[1:])
+	if !found {
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
 	}
 
-	if args[0] == "add" {
-		return t.add(stub, args[1:])
+	actorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
 	}
 
-	if args[0] == "delete" {
-		return t.delete(stub, args[1:])
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
 	}
 
-	if args[0] == "acquire" {
-		return t.acquire(stub, args[1:])
+	objectType, err := actorObjectType(actorType)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
 
-	if args[0] == "release" {
-		return t.release(stub, args[1:])
+	newActor := model.Actor{
+		ID:   actorID,
+		Name: args[0],
+		Org:  actorOrg,
+		Type: actorType,
+	}
+	var newActorObject interface{} = newActor
+	if actorType == model.ActorConsumer {
+		// The priority granted by an admin is kept when a consumer registers again
+		var consumer model.Consumer
+		if getFromLedger(stub, objectType, actorID, &consumer) == nil {
+			newActorObject = model.Consumer{Actor: newActor, Priority: consumer.Priority}
+		}
+	}
+	err = updateInLedger(stub, objectType, actorID, newActorObject)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to register the new %s in the ledger", actorType), err)
+	}
+	newActorAsByte, err := objectToByte(newActor)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable convert the new %s to byte", actorType), err)
 	}
 
-	// If the arguments given don’t match any function, we return an error
-	return shim.Error("Unknown update action, check the second argument.")
+	fmt.Printf("Actor:\n  ID -> %s\n  Name -> %s\n  Org -> %s\n  Type -> %s\n", actorID, args[0], actorOrg, actorType)
+
+	return shim.Success(newActorAsByte)
 }
 
-func (t *ResourceManagerChaincode) register(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func (t *ResourceManagerChaincode) add(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	fmt.Println("# register user")
+	fmt.Println("# add resource")
 
-	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the type of the request owner: %v", err))
-	}
-	if !found {
-		return shim.Error("The type of the request owner is not present")
-	}
+	resourceID := args[0]
+	resourceDescription := args[1]
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	ownerOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
 	}
 
-	actorID, err := cid.GetID(stub)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+	// A resource existing (of any organization) is never overwritten
+	var existing model.Resource
+	if getFromLedger(stub, model.ObjectTypeResource, resourceID, &existing) == nil {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' already exists", resourceID), nil)
 	}
 
-	switch actorType {
-	case model.ActorAdmin:
-		newAdmin := model.Admin{
-			Actor: model.Actor{
-				ID:   actorID,
-				Name: args[0],
-			},
-		}
-		err = updateInLedger(stub, model.ObjectTypeAdmin, actorID, newAdmin)
+	// The resource is shareable with the other organizations only if explicitly asked
+	resourceShareable := false
+	if args[2] != "" {
+		resourceShareable, err = strconv.ParseBool(args[2])
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable to register the new admin in the ledger: %v", err))
+			return errorResponse(model.ErrorInvalidArgument, "The shareable flag is invalid", err)
 		}
-		var newAdminAsByte []byte
-		newAdminAsByte, err = objectToByte(newAdmin)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable convert the new admin to byte: %v", err))
-		}
-
-		fmt.Printf("Admin:\n  ID -> %s\n  Name -> %s\n", actorID, args[0])
+	}
 
-		return shim.Success(newAdminAsByte)
-	case model.ActorConsumer:
-		newConsumer := model.Consumer{
-			Actor: model.Actor{
-				ID:   actorID,
-				Name: args[0],
-			},
-		}
-		err = updateInLedger(stub, model.ObjectTypeConsumer, actorID, newConsumer)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable to register the new consumer in the ledger: %v", err))
-		}
-		newConsumerAsByte, err := objectToByte(newConsumer)
+	// The resource is located in the location tree if a location is given
+	resourceLocation := args[3]
+	if resourceLocation != "" {
+		var location model.Location
+		err = getFromLedger(stub, model.ObjectTypeLocation, resourceLocation, &location)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable convert the new consumer to byte: %v", err))
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the location '%s' in the ledger", resourceLocation), err)
 		}
+	}
 
-		fmt.Printf("Consumer:\n  ID -> %s\n  Name -> %s\n", actorID, args[0])
+	// Every change of the resource must be endorsed by its owner organization
+	resource := model.Resource{
+		ID:            resourceID,
+		Description:   resourceDescription,
+		Owner:         ownerOrg,
+		Shareable:     resourceShareable,
+		Available:     true,
+		Revision:      1,
+		Location:      resourceLocation,
+		EndorsingOrgs: []string{ownerOrg},
+	}
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the resource in the ledger", err)
+	}
 
-		return shim.Success(newConsumerAsByte)
-	default:
-		return shim.Error("The type of the request owner is unknown")
+	err = updateEndorsementInLedger(stub, model.ObjectTypeResource, resourceID, []string{ownerOrg})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to set the endorsement policy of the resource in the ledger", err)
 	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource created:\n  ID -> %s\n  Description -> %s\n  Owner -> %s\n  Shareable -> %t\n  Location -> %s\n", resourceID, resourceDescription, ownerOrg, resourceShareable, resourceLocation)
+
+	return shim.Success(resourceAsByte)
 }
 
-func (t *ResourceManagerChaincode) add(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func (t *ResourceManagerChaincode) edit(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	fmt.Println("# add resource")
+	fmt.Println("# edit resource")
+
+	resourceID := args[0]
+	resourceDescription := args[1]
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorAdmin)
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only admin is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
 	}
 
-	if len(args) < 2 {
-		return shim.Error("The number of arguments is insufficient.")
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
 	}
 
-	resourceID := args[0]
-	if resourceID == "" {
-		return shim.Error("The resource ID is empty.")
+	err = assertRevision(&resource, args, 2)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
 	}
 
-	resourceDescription := args[1]
-	if resourceDescription == "" {
-		return shim.Error("The resource description is empty.")
-	}
+	resource.Description = resourceDescription
+	resource.Revision++
 
-	resource := model.Resource{
-		ID:          resourceID,
-		Description: resourceDescription,
-		Available:   true,
-	}
 	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to create the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
 	}
 
 	resourceAsByte, err := objectToByte(resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable convert the resource to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
 	}
 
-	fmt.Printf("Resource created:\n  ID -> %s\n  Description -> %s\n", resourceID, resourceDescription)
+	fmt.Printf("Resource edited:\n  ID -> %s\n  Description -> %s\n", resourceID, resourceDescription)
 
 	return shim.Success(resourceAsByte)
 }
@@ -168,44 +194,65 @@ func (t *ResourceManagerChaincode) delete(stub shim.ChaincodeStubInterface, args
 
 	fmt.Println("# delete resource")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorAdmin)
+	resourceID := args[0]
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only admin is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the resource in the ledger", err)
 	}
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the owner organization is allowed for the kind of request", err)
 	}
 
-	resourceID := args[0]
-	if resourceID == "" {
-		return shim.Error("The resource ID is empty.")
+	if !resource.Available {
+		return errorResponse(model.ErrorNotAvailable, "The resource can't be deleted because it is currently acquired by a consumer", nil)
 	}
 
-	var resource model.Resource
-	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	primaryID, err := getBundlePrimary(stub, resourceID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the bundles in the ledger", err)
+	}
+	if primaryID != "" {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource can't be deleted because it is a companion required by '%s'", primaryID), nil)
 	}
 
-	if !resource.Available {
-		return shim.Error("The resource can't be deleted because it is currently acquired by a consumer")
+	err = assertRevision(&resource, args, 1)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
 	}
 
 	err = deleteFromLedger(stub, model.ObjectTypeResource, resourceID)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to delete the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to delete the resource in the ledger", err)
 	}
 
 	var resourcesDeleted model.ResourcesDeleted
 	err = getFromLedger(stub, model.ObjectTypeResourcesDeleted, "", &resourcesDeleted)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to retrieve the list of deleted resources in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of deleted resources in the ledger", err)
 	}
 	resourcesDeleted = append(resourcesDeleted, resource)
 	err = updateInLedger(stub, model.ObjectTypeResourcesDeleted, "", resourcesDeleted)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to update the list of deleted resources in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to update the list of deleted resources in the ledger", err)
+	}
+
+	// The reservations of a deleted resource are cancelled, a resource added later with the same ID starts free
+	reservations, err := getReservations(stub, resourceID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the reservations in the ledger", err)
+	}
+	for _, reservation := range reservations {
+		key, err := stub.CreateCompositeKey(model.ObjectTypeReservation, []string{resourceID, reservation.ID})
+		if err == nil {
+			err = stub.DelState(key)
+		}
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to cancel a reservation of the resource in the ledger", err)
+		}
 	}
 
 	fmt.Printf("Resource deleted:\n  ID -> %s\n  Description -> %s\n", resourceID, resource.Description)
@@ -217,55 +264,243 @@ func (t *ResourceManagerChaincode) acquire(stub shim.ChaincodeStubInterface, arg
 
 	fmt.Println("# acquire resource")
 
-	err := cid.AssertAttributeValue(stub, model.ActorAttribute, model.ActorConsumer)
+	resourceID := args[0]
+
+	// The mission is confidential, so it is given through the transient map to never be part of the transaction
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the transient map", err)
+	}
+	// The details of the mission are optional when the resource is acquired for a mission, its title is used instead
+	mission := string(transient[model.TransientMission])
+	if mission == "" && args[5] == "" {
+		return errorResponse(model.ErrorInvalidArgument, "The mission is empty.", nil)
+	}
+	err = model.Validate(model.KindMission, mission)
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The mission is invalid", err)
+	}
+	salt, err := missionSalt(transient)
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The salt of the mission is invalid", err)
+	}
+
+	var resource model.Resource
+	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Only consumer is allowed for the kind of request: %v", err))
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
 	}
 
-	if len(args) < 2 {
-		return shim.Error("The number of arguments is insufficient.")
+	priority, err := model.ParsePriority(args[4])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The priority is invalid", err)
+	}
+	if priority > model.PriorityRoutine {
+		granted, err := grantedPriority(stub)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Unable to retrieve the priority granted to the request owner", err)
+		}
+		if priority > granted {
+			return errorResponse(model.ErrorForbidden, fmt.Sprintf("The priority '%s' is higher than the one granted to you ('%s')", model.PriorityName(priority), model.PriorityName(granted)), nil)
+		}
 	}
 
-	resourceID := args[0]
-	if resourceID == "" {
-		return shim.Error("The resource ID is empty.")
+	// A resource acquired can only be taken from its holder with a priority high enough (preemption), the
+	// companions acquired with it are taken too
+	preempting := !resource.Available
+	if preempting && (resource.Bundle != "" || !model.CanPreempt(priority, resource.Priority)) {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is not available", resourceID), nil)
 	}
 
-	mission := args[1]
-	if mission == "" {
-		return shim.Error("The mission is empty.")
+	if resource.OutOfService {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is out of service", resourceID), nil)
 	}
 
-	var resource model.Resource
-	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	err = assertRevision(&resource, args, 3)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to find the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
 	}
 
-	if !resource.Available {
-		return shim.Error(fmt.Sprintf("The resource ID '%s' is not available", resourceID))
+	if !resource.Shareable && assertOwnerOrg(stub, &resource) != nil {
+		return errorResponse(model.ErrorForbidden, fmt.Sprintf("The resource ID '%s' is not shared with your organization", resourceID), nil)
 	}
 
 	consumerID, err := cid.GetID(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	// The resource can be acquired on behalf of another consumer that granted a delegation to the request owner
+	delegateID := ""
+	if args[2] != "" && args[2] != consumerID {
+		if !isActiveDelegate(stub, args[2], consumerID) {
+			return errorResponse(model.ErrorForbidden, "Unable to acquire a resource on behalf of a consumer that didn't grant you an active delegation", nil)
+		}
+		delegateID = consumerID
+		consumerID = args[2]
+	}
+
+	// The resource can be acquired on behalf of a team the consumer is member of
+	teamID := args[1]
+	if teamID != "" && !isTeamMember(stub, teamID, consumerID) {
+		return errorResponse(model.ErrorForbidden, fmt.Sprintf("Unable to acquire a resource on behalf of the team '%s' you are not member of", teamID), nil)
+	}
+
+	if preempting && (resource.Consumer == consumerID || (teamID != "" && resource.Team == teamID)) {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' is already acquired by you or your team", resourceID), nil)
+	}
+
+	// A resource reserved by another consumer (or team) for the current time slot can't be acquired
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+	for _, reservedID := range append([]string{resourceID}, resource.Companions...) {
+		reservation, err := getActiveReservation(stub, reservedID, txTime)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve the reservations in the ledger", err)
+		}
+		if reservation != nil && !isReservationHolder(stub, reservation, consumerID) {
+			return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is reserved by someone else for the current time slot", reservedID), nil)
+		}
+	}
+
+	// The resource can be acquired for an open mission of the consumer
+	var linkedMission model.Mission
+	if args[5] != "" {
+		err = getFromLedger(stub, model.ObjectTypeMission, args[5], &linkedMission)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the mission '%s' in the ledger", args[5]), err)
+		}
+		if linkedMission.Owner != consumerID {
+			return errorResponse(model.ErrorForbidden, fmt.Sprintf("Unable to acquire a resource for the mission '%s' of another consumer", args[5]), nil)
+		}
+		if !linkedMission.IsOpen(txTime) {
+			return errorResponse(model.ErrorConflict, fmt.Sprintf("The mission '%s' is over", args[5]), nil)
+		}
+		if mission == "" {
+			mission = linkedMission.Title
+		}
+	}
+
+	// Once released, the first holder in line is the only one able to get the resource back for a while
+	claimed := !preempting && len(resource.Preempted) > 0 && resource.ClaimedUntil != nil && txTime.Before(*resource.ClaimedUntil)
+	if claimed && !isFirstInLine(stub, &resource, consumerID) && !model.CanPreempt(priority, resource.Preempted[0].Priority) {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is claimed until %s by the holder it was taken from", resourceID, resource.ClaimedUntil.Format(time.RFC3339)), nil)
+	}
+
+	// The companions required by the resource are acquired with it, in the same transaction, or not at all
+	var companions []model.Resource
+	for _, companionID := range resource.Companions {
+		var companion model.Resource
+		err = getFromLedger(stub, model.ObjectTypeResource, companionID, &companion)
+		if err != nil {
+			return errorResponse(model.ErrorNotFound, fmt.Sprintf("Unable to find the companion resource '%s' in the ledger", companionID), err)
+		}
+		if !companion.Available && !(preempting && companion.Bundle == resourceID) {
+			return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The companion resource ID '%s' required by '%s' is not available", companionID, resourceID), nil)
+		}
+		if companion.OutOfService {
+			return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The companion resource ID '%s' required by '%s' is out of service", companionID, resourceID), nil)
+		}
+		if !companion.Shareable && assertOwnerOrg(stub, &companion) != nil {
+			return errorResponse(model.ErrorForbidden, fmt.Sprintf("The companion resource ID '%s' is not shared with your organization", companionID), nil)
+		}
+		companions = append(companions, companion)
+	}
+
+	// The cost of the acquisition is charged now and the hours when the resource is released
+	err = chargeAcquisition(stub, consumerID, append([]model.Resource{resource}, companions...), resourceID)
+	if noCredit, ok := err.(*model.Error); ok {
+		return errorResponse(noCredit.Code, noCredit.Message, nil)
+	}
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to charge the acquisition in the ledger", err)
+	}
+
+	if preempting {
+		// The holder preempted is charged for the hours it held the resources and placed first in line
+		_, err = moveCredits(stub, resource.Consumer, -usageCost(append([]model.Resource{resource}, companions...), txTime), model.CreditUsage, resourceID)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to charge the usage of the resource in the ledger", err)
+		}
+		preemption := model.Preemption{
+			Consumer:    resource.Consumer,
+			Team:        resource.Team,
+			Priority:    resource.Priority,
+			Preemptor:   consumerID,
+			Transaction: stub.GetTxID(),
+			Time:        txTime,
+		}
+		resource.Preempted = append([]model.Preemption{preemption}, resource.Preempted...)
+	} else if len(resource.Preempted) > 0 && (isFirstInLine(stub, &resource, consumerID) || !claimed) {
+		// The first in line got the resource back, or didn't in time
+		resource.Preempted = resource.Preempted[1:]
 	}
 
 	resource.Consumer = consumerID
-	resource.Mission = mission
+	resource.Team = teamID
+	resource.Delegate = delegateID
+	resource.MissionHash = model.MissionHash(mission, salt)
+	resource.MissionID = args[5]
 	resource.Available = false
+	resource.Report = nil
+	resource.AcquiredAt = &txTime
+	resource.Priority = priority
+	resource.ClaimedUntil = nil
+	resource.Revision++
+
+	for _, companion := range append([]model.Resource{resource}, companions...) {
+		if companion.ID != resourceID {
+			companion.Consumer = resource.Consumer
+			companion.Team = resource.Team
+			companion.Delegate = resource.Delegate
+			companion.MissionHash = resource.MissionHash
+			companion.MissionID = resource.MissionID
+			companion.Available = false
+			companion.Bundle = resourceID
+			companion.Report = nil
+			companion.AcquiredAt = &txTime
+			companion.Priority = resource.Priority
+			companion.Revision++
+		}
 
-	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to update the resource in the ledger: %v", err))
+		err = updateInLedger(stub, model.ObjectTypeResource, companion.ID, companion)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to update the resource '%s' in the ledger", companion.ID), err)
+		}
+
+		resourceMission := model.ResourceMission{
+			ResourceID: companion.ID,
+			Mission:    mission,
+			Consumer:   consumerID,
+			Salt:       salt,
+		}
+		err = updatePrivateInLedger(stub, model.MissionsCollection(companion.Owner), model.ObjectTypeResourceMission, companion.ID, resourceMission)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to store the mission in the private data collection", err)
+		}
+	}
+
+	if linkedMission.ID != "" {
+		for _, acquired := range append([]string{resourceID}, resource.Companions...) {
+			if !linkedMission.HasResource(acquired) {
+				linkedMission.Resources = append(linkedMission.Resources, acquired)
+			}
+		}
+		err = updateInLedger(stub, model.ObjectTypeMission, linkedMission.ID, linkedMission)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to update the mission in the ledger", err)
+		}
 	}
 
+	// The response is part of the transaction, so it only contains the public part of the resource
 	resourceAsByte, err := objectToByte(resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable convert the resource to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
 	}
 
-	fmt.Printf("Resource acquired:\n  ID -> %s\n  Consumer ID -> %s\n  Mission -> %s\n", resourceID, consumerID, mission)
+	fmt.Printf("Resource acquired:\n  ID -> %s\n  Companions -> %v\n  Consumer ID -> %s\n  Team ID -> %s\n  Delegate ID -> %s\n  Mission hash -> %s\n  Priority -> %d\n  Preempted -> %t\n", resourceID, resource.Companions, consumerID, teamID, delegateID, resource.MissionHash, priority, preempting)
 
 	return shim.Success(resourceAsByte)
 }
@@ -274,67 +509,929 @@ func (t *ResourceManagerChaincode) release(stub shim.ChaincodeStubInterface, arg
 
 	fmt.Println("# release resource")
 
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
-	}
-
 	resourceID := args[0]
-	if resourceID == "" {
-		return shim.Error("The resource ID is empty.")
-	}
 
 	var resource model.Resource
 	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to find the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
 	}
 
 	if resource.Available {
-		return shim.Error(fmt.Sprintf("The resource ID '%s' is not acquired", resourceID))
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is not acquired", resourceID), nil)
+	}
+
+	if resource.Bundle != "" {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' is part of the bundle of '%s', release this resource instead", resourceID, resource.Bundle), nil)
 	}
 
 	actorType, found, err := cid.GetAttributeValue(stub, model.ActorAttribute)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to identify the type of the request owner: %v", err))
+		return errorResponse(model.ErrorForbidden, "Unable to identify the type of the request owner", err)
 	}
 	if !found {
-		return shim.Error("The type of the request owner is not present")
-	}
-
-	if len(args) < 1 {
-		return shim.Error("The number of arguments is insufficient.")
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is not present", nil)
 	}
 
+	delegateID := ""
 	switch actorType {
-	case model.ActorAdmin:
+	case model.ActorAdmin, model.ActorManager:
+		// Admins and managers force the release of a resource acquired by any consumer
+		err = assertOwnerOrg(stub, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
+		}
 	case model.ActorConsumer:
 		var consumerID string
 		consumerID, err = cid.GetID(stub)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Unable to identify the ID of the request owner: %v", err))
+			return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
 		}
-		if consumerID != resource.Consumer {
-			return shim.Error("Unable to release a resource that you don't previously acquire")
+		if consumerID != resource.Consumer && !isTeamMember(stub, resource.Team, consumerID) {
+			// The resource can be released on behalf of the consumer that granted a delegation to the request owner
+			if !isActiveDelegate(stub, resource.Consumer, consumerID) {
+				return errorResponse(model.ErrorForbidden, "Unable to release a resource that you or your team don't previously acquire", nil)
+			}
+			delegateID = consumerID
 		}
 	default:
-		return shim.Error("The type of the request owner is unknown")
+		return errorResponse(model.ErrorForbidden, "The type of the request owner is unknown", nil)
 	}
 
-	resource.Consumer = ""
-	resource.Mission = ""
-	resource.Available = true
+	err = assertRevision(&resource, args, 1)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
 
-	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	report, err := conditionReport(stub, args[2], args[3], args[4])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The condition report is invalid", err)
+	}
+
+	// The companions acquired with the resource are returned together
+	companions, err := getBundle(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the bundle of the resource in the ledger", err)
+	}
+
+	resource, err = releaseBundle(stub, resource, companions, delegateID, report)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable to update the resource in the ledger: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable to release the resource in the ledger", err)
 	}
 
 	resourceAsByte, err := objectToByte(resource)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("Unable convert the resource to byte: %v", err))
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
 	}
 
-	fmt.Printf("Resource release:\n  ID -> %s\n", resourceID)
+	fmt.Printf("Resource release:\n  ID -> %s\n  Out of service -> %t\n", resourceID, resource.OutOfService)
 
 	return shim.Success(resourceAsByte)
 }
+
+// chargeAcquisition charge the cost per use of the resources acquired to the consumer, who must have enough credits
+// for the acquisition and its first hour, else a model.Error with the code NO_CREDIT is returned
+func chargeAcquisition(stub shim.ChaincodeStubInterface, consumerID string, resources []model.Resource, resourceID string) error {
+	var useCost, hourCost int64
+	for _, charged := range resources {
+		useCost += charged.CostPerUse
+		hourCost += charged.CostPerHour
+	}
+	if useCost+hourCost == 0 {
+		return nil
+	}
+	account, err := getAccount(stub, consumerID)
+	if err != nil {
+		return err
+	}
+	if account.Balance < useCost+hourCost {
+		return &model.Error{Code: model.ErrorNoCredit, Message: fmt.Sprintf("The acquisition and its first hour cost %d credits, the balance is %d credits", useCost+hourCost, account.Balance)}
+	}
+	_, err = moveCredits(stub, consumerID, -useCost, model.CreditAcquisition, resourceID)
+	return err
+}
+
+// releaseBundle release a resource with the companions acquired with it, the hours they were held are charged to
+// the consumer, even if it makes the balance negative. The resource released is returned, with the condition report
+// given (if any), a resource reported damaged is put out of service.
+func releaseBundle(stub shim.ChaincodeStubInterface, resource model.Resource, companions []model.Resource, delegateID string, report *model.ConditionReport) (model.Resource, error) {
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return resource, err
+	}
+	_, err = moveCredits(stub, resource.Consumer, -usageCost(append([]model.Resource{resource}, companions...), txTime), model.CreditUsage, resource.ID)
+	if err != nil {
+		return resource, fmt.Errorf("unable to charge the usage of the resource: %v", err)
+	}
+	return returnBundle(stub, resource, companions, delegateID, report, txTime)
+}
+
+// returnBundle make a resource and the companions acquired with it available again, without charging their usage
+func returnBundle(stub shim.ChaincodeStubInterface, resource model.Resource, companions []model.Resource, delegateID string, report *model.ConditionReport, txTime time.Time) (model.Resource, error) {
+	resourceID := resource.ID
+	for _, released := range append([]model.Resource{resource}, companions...) {
+		released.Consumer = ""
+		released.Team = ""
+		released.Delegate = delegateID
+		released.Mission = ""
+		released.MissionHash = ""
+		released.MissionID = ""
+		released.Available = true
+		released.Bundle = ""
+		released.Report = nil
+		released.AcquiredAt = nil
+		released.CheckedInAt = nil
+		released.Priority = model.PriorityRoutine
+		released.Revision++
+		if released.ID == resourceID && len(released.Preempted) > 0 {
+			// The first holder in line is given some time to get the resource back
+			claimedUntil := txTime.Add(model.PreemptionClaimDuration)
+			released.ClaimedUntil = &claimedUntil
+		}
+		if released.ID == resourceID {
+			// A resource reported damaged is put out of service
+			released.Report = report
+			released.OutOfService = released.OutOfService || (report != nil && report.Condition == model.ConditionDamaged)
+			resource = released
+		}
+
+		err := updateInLedger(stub, model.ObjectTypeResource, released.ID, released)
+		if err != nil {
+			return resource, fmt.Errorf("unable to update the resource '%s': %v", released.ID, err)
+		}
+
+		err = deleteResourceMission(stub, &released)
+		if err != nil {
+			return resource, fmt.Errorf("unable to delete the mission of the resource '%s': %v", released.ID, err)
+		}
+	}
+	return resource, nil
+}
+
+// missionSalt retrieve the salt of the hash of the mission given through the transient map
+func missionSalt(transient map[string][]byte) ([]byte, error) {
+	salt := transient[model.TransientMissionSalt]
+	if len(salt) < model.MissionSaltSize {
+		return nil, fmt.Errorf("the salt of the mission must have at least %d bytes", model.MissionSaltSize)
+	}
+	return salt, nil
+}
+
+// getResourceMission retrieve the private mission of a resource acquired, from the collection of its owner
+// organization or else from the collection shared by the organizations before, or else from the world state for the
+// resources acquired before the missions were private; the mission is empty if the resource has none
+func getResourceMission(stub shim.ChaincodeStubInterface, resource *model.Resource) (model.ResourceMission, error) {
+	resourceMission := model.ResourceMission{ResourceID: resource.ID, Mission: resource.Mission, Consumer: resource.Consumer}
+	key, err := stub.CreateCompositeKey(model.ObjectTypeResourceMission, []string{resource.ID})
+	if err != nil {
+		return resourceMission, fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+	for _, collection := range []string{model.MissionsCollection(resource.Owner), model.CollectionMissions} {
+		missionAsByte, err := stub.GetPrivateData(collection, key)
+		if err != nil {
+			return resourceMission, fmt.Errorf("unable to retrieve the mission in the private data collection '%s': %v", collection, err)
+		}
+		if missionAsByte != nil {
+			err = byteToObject(missionAsByte, &resourceMission)
+			if err != nil {
+				return resourceMission, fmt.Errorf("unable to convert the mission: %v", err)
+			}
+			return resourceMission, nil
+		}
+	}
+	return resourceMission, nil
+}
+
+// putResourceMission store the private mission of a resource in the collection of its owner organization, the one
+// stored in the collection shared by the organizations before is deleted
+func putResourceMission(stub shim.ChaincodeStubInterface, resource *model.Resource, resourceMission model.ResourceMission) error {
+	err := updatePrivateInLedger(stub, model.MissionsCollection(resource.Owner), model.ObjectTypeResourceMission, resource.ID, resourceMission)
+	if err != nil {
+		return err
+	}
+	if model.MissionsCollection(resource.Owner) == model.CollectionMissions {
+		return nil
+	}
+	return deletePrivateFromLedger(stub, model.CollectionMissions, model.ObjectTypeResourceMission, resource.ID)
+}
+
+// deleteResourceMission delete the private mission of a resource, wherever it is stored
+func deleteResourceMission(stub shim.ChaincodeStubInterface, resource *model.Resource) error {
+	err := deletePrivateFromLedger(stub, model.MissionsCollection(resource.Owner), model.ObjectTypeResourceMission, resource.ID)
+	if err != nil {
+		return err
+	}
+	if model.MissionsCollection(resource.Owner) == model.CollectionMissions {
+		return nil
+	}
+	return deletePrivateFromLedger(stub, model.CollectionMissions, model.ObjectTypeResourceMission, resource.ID)
+}
+
+// conditionReport build the condition report given on release, nil if no condition is given
+func conditionReport(stub shim.ChaincodeStubInterface, condition string, notes string, meter string) (*model.ConditionReport, error) {
+	if condition == "" {
+		if notes != "" || meter != "" {
+			return nil, fmt.Errorf("the condition of the resource is required to report notes or a meter reading")
+		}
+		return nil, nil
+	}
+	known := false
+	for _, c := range model.Conditions {
+		known = known || c == condition
+	}
+	if !known {
+		return nil, fmt.Errorf("the condition '%s' is unknown", condition)
+	}
+	report := &model.ConditionReport{Condition: condition, Notes: notes, Transaction: stub.GetTxID()}
+	if meter != "" {
+		reading, err := strconv.ParseFloat(meter, 64)
+		if err != nil || reading < 0 || math.IsInf(reading, 0) || math.IsNaN(reading) {
+			return nil, fmt.Errorf("the meter reading '%s' is not a positive number", meter)
+		}
+		report.Meter = &reading
+	}
+	return report, nil
+}
+
+func (t *ResourceManagerChaincode) restore(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# restore resource")
+
+	resourceID := args[0]
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 1)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	if !resource.OutOfService {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' is not out of service", resourceID), nil)
+	}
+
+	resource.OutOfService = false
+	resource.Report = nil
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource restored:\n  ID -> %s\n", resourceID)
+
+	return shim.Success(resourceAsByte)
+}
+
+func (t *ResourceManagerChaincode) share(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# share resource")
+
+	resourceID := args[0]
+
+	resourceShareable, err := strconv.ParseBool(args[1])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The shareable flag is invalid", err)
+	}
+
+	var resource model.Resource
+	err = getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin or manager of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 2)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	resource.Shareable = resourceShareable
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource shared:\n  ID -> %s\n  Shareable -> %t\n", resourceID, resourceShareable)
+
+	return shim.Success(resourceAsByte)
+}
+
+func (t *ResourceManagerChaincode) setEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# set endorsement policy of resource")
+
+	resourceID := args[0]
+
+	// The endorsing organizations are given as a comma separated list of MSP IDs, an empty list restore the default
+	// policy: the owner organization only
+	var endorsingOrgs []string
+	for _, org := range strings.Split(args[1], ",") {
+		if org = strings.TrimSpace(org); org != "" {
+			endorsingOrgs = append(endorsingOrgs, org)
+		}
+	}
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	err = assertOwnerOrg(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the owner organization is allowed for the kind of request", err)
+	}
+
+	err = assertRevision(&resource, args, 2)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	if len(endorsingOrgs) == 0 && resource.Owner != "" {
+		endorsingOrgs = []string{resource.Owner}
+	}
+
+	// The owner organization can't be excluded from the endorsement of its own resource
+	if len(endorsingOrgs) > 0 && resource.Owner != "" {
+		ownerIncluded := false
+		for _, org := range endorsingOrgs {
+			ownerIncluded = ownerIncluded || org == resource.Owner
+		}
+		if !ownerIncluded {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The owner organization '%s' must be part of the endorsing organizations", resource.Owner), nil)
+		}
+	}
+
+	resource.EndorsingOrgs = endorsingOrgs
+	resource.Revision++
+
+	err = updateInLedger(stub, model.ObjectTypeResource, resourceID, resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the resource in the ledger", err)
+	}
+
+	err = updateEndorsementInLedger(stub, model.ObjectTypeResource, resourceID, endorsingOrgs)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to set the endorsement policy of the resource in the ledger", err)
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource endorsement policy set:\n  ID -> %s\n  Endorsing organizations -> %v\n", resourceID, endorsingOrgs)
+
+	return shim.Success(resourceAsByte)
+}
+
+func (t *ResourceManagerChaincode) renew(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# renew resource")
+
+	resourceID := args[0]
+
+	var resource model.Resource
+	err := getFromLedger(stub, model.ObjectTypeResource, resourceID, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to find the resource in the ledger", err)
+	}
+
+	if resource.Available {
+		return errorResponse(model.ErrorNotAvailable, fmt.Sprintf("The resource ID '%s' is not acquired", resourceID), nil)
+	}
+
+	if resource.Bundle != "" {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The resource ID '%s' is part of the bundle of '%s', renew this resource instead", resourceID, resource.Bundle), nil)
+	}
+
+	consumerID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+	if consumerID != resource.Consumer && !isTeamMember(stub, resource.Team, consumerID) {
+		return errorResponse(model.ErrorForbidden, "Unable to renew a resource that you or your team don't previously acquire", nil)
+	}
+
+	err = assertRevision(&resource, args, 1)
+	if err != nil {
+		return errorResponse(model.ErrorConflict, "The resource changed since the revision expected", err)
+	}
+
+	// The consumer renewing take over the holding, with a new mission if given through the transient map
+	// The resources acquired before the missions were private have no mission in private data, it is empty then.
+	resourceMission, err := getResourceMission(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the mission in the private data collection", err)
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the transient map", err)
+	}
+	if mission := string(transient[model.TransientMission]); mission != "" {
+		err = model.Validate(model.KindMission, mission)
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The mission is invalid", err)
+		}
+		resourceMission.Mission = mission
+	}
+	resourceMission.Consumer = consumerID
+	// The mission is hashed again with a new salt, if given
+	if _, given := transient[model.TransientMissionSalt]; given || len(resourceMission.Salt) == 0 {
+		resourceMission.Salt, err = missionSalt(transient)
+		if err != nil {
+			return errorResponse(model.ErrorInvalidArgument, "The salt of the mission is invalid", err)
+		}
+	}
+
+	// The companions acquired with the resource follow its holding
+	companions, err := getBundle(stub, &resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the bundle of the resource in the ledger", err)
+	}
+
+	// A member of the team taking over the holding is charged the acquisition, as when acquiring the resources
+	if consumerID != resource.Consumer {
+		err = chargeAcquisition(stub, consumerID, append([]model.Resource{resource}, companions...), resourceID)
+		if noCredit, ok := err.(*model.Error); ok {
+			return errorResponse(noCredit.Code, noCredit.Message, nil)
+		}
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to charge the acquisition in the ledger", err)
+		}
+	}
+
+	// The hours held until the renewal are charged to the previous holder, the next ones to the consumer renewing
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the time of the transaction", err)
+	}
+	_, err = moveCredits(stub, resource.Consumer, -usageCost(append([]model.Resource{resource}, companions...), txTime), model.CreditUsage, resourceID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to charge the usage of the resource in the ledger", err)
+	}
+
+	for _, renewed := range append([]model.Resource{resource}, companions...) {
+		renewed.Consumer = consumerID
+		renewed.AcquiredAt = &txTime
+		renewed.MissionHash = model.MissionHash(resourceMission.Mission, resourceMission.Salt)
+		renewed.Mission = ""
+		renewed.Revision++
+		if renewed.ID == resourceID {
+			resource = renewed
+		}
+
+		err = updateInLedger(stub, model.ObjectTypeResource, renewed.ID, renewed)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, fmt.Sprintf("Unable to update the resource '%s' in the ledger", renewed.ID), err)
+		}
+
+		renewedMission := resourceMission
+		renewedMission.ResourceID = renewed.ID
+		err = putResourceMission(stub, &renewed, renewedMission)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to store the mission in the private data collection", err)
+		}
+	}
+
+	resourceAsByte, err := objectToByte(resource)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the resource to byte", err)
+	}
+
+	fmt.Printf("Resource renewed:\n  ID -> %s\n  Consumer ID -> %s\n  Team ID -> %s\n", resourceID, consumerID, resource.Team)
+
+	return shim.Success(resourceAsByte)
+}
+
+func (t *ResourceManagerChaincode) migrate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# migrate ledger")
+
+	// The number of objects migrated in the transaction can be given, to keep the transaction reasonably small
+	batchSize := defaultMigrationBatchSize
+	if args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return errorResponse(model.ErrorInvalidArgument, fmt.Sprintf("The batch size '%s' is invalid", args[0]), nil)
+		}
+		batchSize = size
+	}
+
+	schema, err := runMigrations(stub, batchSize, args[1], false)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to migrate the ledger", err)
+	}
+
+	schemaAsByte, err := objectToByte(schema)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the schema to byte", err)
+	}
+
+	fmt.Printf("Ledger migrated:\n  Version -> %d\n  Latest -> %d\n  Bookmark -> %s\n", schema.Version, schema.Latest, schema.Bookmark)
+
+	return shim.Success(schemaAsByte)
+}
+
+func (t *ResourceManagerChaincode) addTeam(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# add team")
+
+	teamID := args[0]
+	teamName := args[1]
+
+	var existingTeam model.Team
+	if getFromLedger(stub, model.ObjectTypeTeam, teamID, &existingTeam) == nil {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The team ID '%s' already exist", teamID), nil)
+	}
+
+	teamOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the organization of the request owner", err)
+	}
+
+	team := model.Team{
+		ID:      teamID,
+		Name:    teamName,
+		Org:     teamOrg,
+		Members: []string{},
+	}
+	err = updateInLedger(stub, model.ObjectTypeTeam, teamID, team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to create the team in the ledger", err)
+	}
+
+	teamAsByte, err := objectToByte(team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the team to byte", err)
+	}
+
+	fmt.Printf("Team created:\n  ID -> %s\n  Name -> %s\n  Org -> %s\n", teamID, teamName, teamOrg)
+
+	return shim.Success(teamAsByte)
+}
+
+func (t *ResourceManagerChaincode) deleteTeam(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# delete team")
+
+	teamID := args[0]
+
+	var team model.Team
+	err := getFromLedger(stub, model.ObjectTypeTeam, teamID, &team)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the team in the ledger", err)
+	}
+
+	err = assertOrg(stub, team.Org)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the team organization is allowed for the kind of request", err)
+	}
+
+	// A team still holding resources can't be deleted, its members would no longer be able to release them
+	iterator, err := stub.GetStateByPartialCompositeKey(model.ObjectTypeResource, []string{})
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the list of resource in the ledger", err)
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		keyValueState, errIt := iterator.Next()
+		if errIt != nil {
+			return errorResponse(model.ErrorInternal, "Unable to retrieve a resource in the ledger", errIt)
+		}
+		var resource model.Resource
+		err = byteToObject(keyValueState.Value, &resource)
+		if err != nil {
+			return errorResponse(model.ErrorInternal, "Unable to convert a resource", err)
+		}
+		if resource.Team == teamID && !resource.Available {
+			return errorResponse(model.ErrorConflict, fmt.Sprintf("The team can't be deleted because it holds the resource '%s'", resource.ID), nil)
+		}
+	}
+
+	err = deleteFromLedger(stub, model.ObjectTypeTeam, teamID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to delete the team in the ledger", err)
+	}
+
+	fmt.Printf("Team deleted:\n  ID -> %s\n  Name -> %s\n", teamID, team.Name)
+
+	return shim.Success(nil)
+}
+
+func (t *ResourceManagerChaincode) addTeamMember(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# add team member")
+
+	teamID := args[0]
+	consumerID := args[1]
+
+	var team model.Team
+	err := getFromLedger(stub, model.ObjectTypeTeam, teamID, &team)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the team in the ledger", err)
+	}
+
+	err = assertOrg(stub, team.Org)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the team organization is allowed for the kind of request", err)
+	}
+
+	var consumer model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, consumerID, &consumer)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the consumer in the ledger", err)
+	}
+
+	if team.HasMember(consumerID) {
+		return errorResponse(model.ErrorConflict, fmt.Sprintf("The consumer '%s' is already member of the team", consumer.Name), nil)
+	}
+	team.Members = append(team.Members, consumerID)
+
+	err = updateInLedger(stub, model.ObjectTypeTeam, teamID, team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the team in the ledger", err)
+	}
+
+	teamAsByte, err := objectToByte(team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the team to byte", err)
+	}
+
+	fmt.Printf("Team member added:\n  Team ID -> %s\n  Consumer ID -> %s\n", teamID, consumerID)
+
+	return shim.Success(teamAsByte)
+}
+
+func (t *ResourceManagerChaincode) removeTeamMember(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# remove team member")
+
+	teamID := args[0]
+	consumerID := args[1]
+
+	var team model.Team
+	err := getFromLedger(stub, model.ObjectTypeTeam, teamID, &team)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the team in the ledger", err)
+	}
+
+	err = assertOrg(stub, team.Org)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Only admin of the team organization is allowed for the kind of request", err)
+	}
+
+	if !team.HasMember(consumerID) {
+		return errorResponse(model.ErrorNotFound, "The consumer is not member of the team", nil)
+	}
+	members := make([]string, 0, len(team.Members))
+	for _, member := range team.Members {
+		if member != consumerID {
+			members = append(members, member)
+		}
+	}
+	team.Members = members
+
+	err = updateInLedger(stub, model.ObjectTypeTeam, teamID, team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the team in the ledger", err)
+	}
+
+	teamAsByte, err := objectToByte(team)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the team to byte", err)
+	}
+
+	fmt.Printf("Team member removed:\n  Team ID -> %s\n  Consumer ID -> %s\n", teamID, consumerID)
+
+	return shim.Success(teamAsByte)
+}
+
+func (t *ResourceManagerChaincode) grantDelegation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# grant delegation")
+
+	delegateID := args[0]
+
+	expiration, err := time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		return errorResponse(model.ErrorInvalidArgument, "The expiration is not a valid RFC 3339 time", err)
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to identify the time of the transaction", err)
+	}
+	if !expiration.After(now) {
+		return errorResponse(model.ErrorInvalidArgument, "The expiration of the delegation is already passed.", nil)
+	}
+
+	delegatorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+	if delegatorID == delegateID {
+		return errorResponse(model.ErrorInvalidArgument, "Unable to grant a delegation to yourself.", nil)
+	}
+
+	var delegator model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, delegatorID, &delegator)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the consumer in the ledger", err)
+	}
+
+	var delegate model.Consumer
+	err = getFromLedger(stub, model.ObjectTypeConsumer, delegateID, &delegate)
+	if err != nil {
+		return errorResponse(model.ErrorNotFound, "Unable to retrieve the delegate in the ledger", err)
+	}
+
+	delegations, err := getDelegations(stub, delegatorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the delegations in the ledger", err)
+	}
+
+	// A new delegation to the same delegate replace the previous one
+	newDelegations := model.Delegations{{
+		Delegator:     delegatorID,
+		DelegatorName: delegator.Name,
+		Delegate:      delegateID,
+		DelegateName:  delegate.Name,
+		Expiration:    expiration,
+	}}
+	for _, delegation := range delegations {
+		if delegation.Delegate != delegateID {
+			newDelegations = append(newDelegations, delegation)
+		}
+	}
+
+	err = updateInLedger(stub, model.ObjectTypeDelegations, delegatorID, newDelegations)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the delegations in the ledger", err)
+	}
+
+	delegationAsByte, err := objectToByte(newDelegations[0])
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable convert the delegation to byte", err)
+	}
+
+	fmt.Printf("Delegation granted:\n  Delegator ID -> %s\n  Delegate ID -> %s\n  Expiration -> %s\n", delegatorID, delegateID, expiration)
+
+	return shim.Success(delegationAsByte)
+}
+
+func (t *ResourceManagerChaincode) revokeDelegation(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	fmt.Println("# revoke delegation")
+
+	delegateID := args[0]
+
+	delegatorID, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(model.ErrorForbidden, "Unable to identify the ID of the request owner", err)
+	}
+
+	delegations, err := getDelegations(stub, delegatorID)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to retrieve the delegations in the ledger", err)
+	}
+
+	newDelegations := make(model.Delegations, 0, len(delegations))
+	for _, delegation := range delegations {
+		if delegation.Delegate != delegateID {
+			newDelegations = append(newDelegations, delegation)
+		}
+	}
+	if len(newDelegations) == len(delegations) {
+		return errorResponse(model.ErrorNotFound, "No delegation granted to the delegate given.", nil)
+	}
+
+	err = updateInLedger(stub, model.ObjectTypeDelegations, delegatorID, newDelegations)
+	if err != nil {
+		return errorResponse(model.ErrorInternal, "Unable to update the delegations in the ledger", err)
+	}
+
+	fmt.Printf("Delegation revoked:\n  Delegator ID -> %s\n  Delegate ID -> %s\n", delegatorID, delegateID)
+
+	return shim.Success(nil)
+}
+
+// getDelegations retrieve the delegations granted by the given consumer, an empty list if none
+func getDelegations(stub shim.ChaincodeStubInterface, delegatorID string) (model.Delegations, error) {
+	key, err := stub.CreateCompositeKey(model.ObjectTypeDelegations, []string{delegatorID})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+	delegationsAsByte, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the delegations in the ledger: %v", err)
+	}
+	delegations := make(model.Delegations, 0)
+	if delegationsAsByte == nil {
+		return delegations, nil
+	}
+	err = byteToObject(delegationsAsByte, &delegations)
+	if err != nil {
+		return nil, err
+	}
+	return delegations, nil
+}
+
+// isActiveDelegate check whether the given delegator granted an active delegation to the given delegate
+func isActiveDelegate(stub shim.ChaincodeStubInterface, delegatorID string, delegateID string) bool {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return false
+	}
+	delegations, err := getDelegations(stub, delegatorID)
+	if err != nil {
+		return false
+	}
+	for _, delegation := range delegations {
+		if delegation.Delegate == delegateID && delegation.IsActive(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTeamMember check whether the given consumer is member of the given team
+func isTeamMember(stub shim.ChaincodeStubInterface, teamID string, consumerID string) bool {
+	if teamID == "" {
+		return false
+	}
+	var team model.Team
+	err := getFromLedger(stub, model.ObjectTypeTeam, teamID, &team)
+	if err != nil {
+		return false
+	}
+	return team.HasMember(consumerID)
+}
+
+// assertRevision check that the resource is still at the revision expected by the request owner
+// The expected revision is an optional argument at the given index, the check is skipped if it is absent or empty.
+func assertRevision(resource *model.Resource, args []string, index int) error {
+	if len(args) <= index || args[index] == "" {
+		return nil
+	}
+	expectedRevision, err := strconv.ParseUint(args[index], 10, 64)
+	if err != nil {
+		return fmt.Errorf("the expected revision '%s' is invalid: %v", args[index], err)
+	}
+	if resource.Revision != expectedRevision {
+		return fmt.Errorf("%s, the resource ID '%s' is at revision %d instead of %d", model.ErrorRevisionConflict, resource.ID, resource.Revision, expectedRevision)
+	}
+	return nil
+}
+
+// assertOwnerOrg check that the request owner belongs to the organization owning the given resource.
+// Resources recorded without an owner are considered as owned by every organization.
+func assertOwnerOrg(stub shim.ChaincodeStubInterface, resource *model.Resource) error {
+	if resource.Owner == "" {
+		return nil
+	}
+	err := assertOrg(stub, resource.Owner)
+	if err != nil {
+		return fmt.Errorf("the resource is owned by the organization '%s'", resource.Owner)
+	}
+	return nil
+}
+
+// assertOrg check that the request owner belongs to the given organization
+func assertOrg(stub shim.ChaincodeStubInterface, org string) error {
+	actorOrg, err := cid.GetMSPID(stub)
+	if err != nil {
+		return fmt.Errorf("unable to identify the organization of the request owner: %v", err)
+	}
+	if actorOrg != org {
+		return fmt.Errorf("the request owner doesn't belong to the organization '%s'", org)
+	}
+	return nil
+}
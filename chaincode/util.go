@@ -17,9 +17,28 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/chainHero/resource-manager/chaincode/model"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"time"
 )
 
+// errorResponse build the response of a request that failed with a structured error (see model.Error)
+// The message is shown to the user, the error given (if any) is kept as details of the failure.
+func errorResponse(code string, message string, err error) pb.Response {
+	responseError := model.Error{Code: code, Message: message}
+	if err != nil {
+		responseError.Details = err.Error()
+	}
+	fmt.Printf("Error:\n  Code -> %s\n  Message -> %s\n  Details -> %s\n", responseError.Code, responseError.Message, responseError.Details)
+	responseErrorAsByte, err := objectToByte(responseError)
+	if err != nil {
+		return shim.Error(message)
+	}
+	return shim.Error(string(responseErrorAsByte))
+}
+
 // objectToByte convert the given object to a slice of byte
 func objectToByte(object interface{}) ([]byte, error) {
 	objectAsByte, err := json.Marshal(object)
@@ -38,6 +57,15 @@ func byteToObject(objectAsByte []byte, result interface{}) error {
 	return nil
 }
 
+// getTxTime retrieve the time of the transaction, the same for every peer endorsing it
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to retrieve the transaction timestamp: %v", err)
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
 // getFromLedger retrieve an object from the ledger
 func getFromLedger(stub shim.ChaincodeStubInterface, objectType string, id string, result interface{}) error {
 	key, err := stub.CreateCompositeKey(objectType, []string{id})
@@ -76,6 +104,37 @@ func updateInLedger(stub shim.ChaincodeStubInterface, objectType string, id stri
 	return nil
 }
 
+// updateEndorsementInLedger set the key-level endorsement policy of an object in the ledger
+// Every change of the object must then be endorsed by a peer of each organization given (by MSP ID),
+// the policy is removed (the chaincode endorsement policy applies again) if no organization is given.
+func updateEndorsementInLedger(stub shim.ChaincodeStubInterface, objectType string, id string, orgs []string) error {
+	key, err := stub.CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+
+	var policy []byte
+	if len(orgs) > 0 {
+		endorsementPolicy, err := statebased.NewStateEP(nil)
+		if err != nil {
+			return fmt.Errorf("unable to create the endorsement policy: %v", err)
+		}
+		err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...)
+		if err != nil {
+			return fmt.Errorf("unable to add the organizations to the endorsement policy: %v", err)
+		}
+		policy, err = endorsementPolicy.Policy()
+		if err != nil {
+			return fmt.Errorf("unable to build the endorsement policy: %v", err)
+		}
+	}
+	err = stub.SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("unable to set the endorsement policy of the object in the ledger: %v", err)
+	}
+	return nil
+}
+
 // deleteFromLedger delete an object in the ledger
 func deleteFromLedger(stub shim.ChaincodeStubInterface, objectType string, id string) error {
 	key, err := stub.CreateCompositeKey(objectType, []string{id})
@@ -88,3 +147,54 @@ func deleteFromLedger(stub shim.ChaincodeStubInterface, objectType string, id st
 	}
 	return nil
 }
+
+// getPrivateFromLedger retrieve an object from a private data collection of the ledger
+func getPrivateFromLedger(stub shim.ChaincodeStubInterface, collection string, objectType string, id string, result interface{}) error {
+	key, err := stub.CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+	resultAsByte, err := stub.GetPrivateData(collection, key)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve the object in the private data collection: %v", err)
+	}
+	if resultAsByte == nil {
+		return fmt.Errorf("the object doesn't exist in the private data collection")
+	}
+	err = byteToObject(resultAsByte, result)
+	if err != nil {
+		return fmt.Errorf("unable to convert the result to object: %v", err)
+	}
+	return nil
+}
+
+// updatePrivateInLedger update an object in a private data collection of the ledger
+func updatePrivateInLedger(stub shim.ChaincodeStubInterface, collection string, objectType string, id string, object interface{}) error {
+	key, err := stub.CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+
+	objectAsByte, err := objectToByte(object)
+	if err != nil {
+		return err
+	}
+	err = stub.PutPrivateData(collection, key, objectAsByte)
+	if err != nil {
+		return fmt.Errorf("unable to put the object in the private data collection: %v", err)
+	}
+	return nil
+}
+
+// deletePrivateFromLedger delete an object in a private data collection of the ledger
+func deletePrivateFromLedger(stub shim.ChaincodeStubInterface, collection string, objectType string, id string) error {
+	key, err := stub.CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("unable to create the object key for the ledger: %v", err)
+	}
+	err = stub.DelPrivateData(collection, key)
+	if err != nil {
+		return fmt.Errorf("unable to delete the object in the private data collection: %v", err)
+	}
+	return nil
+}